@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/L-P/poussin/emu/disasm"
+)
+
+// runDisasm writes the RGBDS source of a ROM.
+func runDisasm(args []string) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	output := fs.String("o", "", "write the listing to `file` instead of stdout")
+	var patches stringList
+	fs.Var(&patches, "patch", "disassemble the ROM patched with `file`, can be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: poussin disasm [-o FILE] [-patch FILE]... ROM")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	rom, err := loadROM(fs.Arg(0), patches)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return writeDisasm(os.Stdout, fs.Arg(0), rom.Data)
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeDisasm(f, fs.Arg(0), rom.Data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeDisasm(w io.Writer, path string, rom []byte) error {
+	if _, err := fmt.Fprintf(w, "; Disassembly of %s, build with rgbasm and rgblink.\n\n", path); err != nil {
+		return err
+	}
+
+	return disasm.Analyze(rom).WriteASM(w)
+}
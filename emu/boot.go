@@ -0,0 +1,113 @@
+package emu
+
+import (
+	"fmt"
+
+	"github.com/L-P/poussin/emu/rom"
+)
+
+const (
+	// Dots per frame.
+	frameDots = 456 * 154
+
+	// The logo starts above the screen and scrolls down one line every
+	// scrollFrames frames, then stays still for holdFrames.
+	logoStartSCY = 0x64
+	scrollFrames = 2
+	holdFrames   = 40
+)
+
+// registeredTile is the ® drawn next to the logo.
+var registeredTile = [8]byte{0x3C, 0x42, 0xB9, 0xA5, 0xB9, 0xA5, 0x42, 0x3C}
+
+// EnableBootAnimation makes Run play the boot animation using the logo from
+// the cartridge instead of running a boot ROM.
+func (g *Gameboy) EnableBootAnimation() {
+	g.bootAnimation = true
+}
+
+// PlayBootAnimation scrolls the cartridge logo down the screen as the boot ROM
+// would, then checks the header checksum and puts the machine in its
+// post-boot state. The CPU is locked up on checksum mismatch like on real
+// hardware.
+func (g *Gameboy) PlayBootAnimation() error {
+	// The animation is drawn in DMG colors on all models.
+	g.ppu.SetCGB(false, false)
+	g.loadLogo()
+
+	g.ppu.LCDC = 0x91
+	g.ppu.BGP = 0xFC
+	g.ppu.Seek(0, 0)
+
+	frames := logoStartSCY*scrollFrames + holdFrames
+	for i := 0; i < frames && !g.closed(); i++ {
+		scy := logoStartSCY - i/scrollFrames
+		if scy < 0 {
+			scy = 0
+		}
+		g.ppu.SCY = byte(scy)
+
+		for dot := 0; dot < frameDots; dot++ {
+			g.ppu.Cycle()
+		}
+	}
+
+	expected := g.cpu.ROM[rom.HeaderChecksum]
+	if actual := rom.ComputeHeaderChecksum(g.cpu.ROM[:]); actual != expected {
+		g.cpu.WriteIE(0)
+		g.cpu.InterruptMaster = false
+		g.cpu.Halted = true
+		return fmt.Errorf("header checksum mismatch: %02X, expected %02X", actual, expected)
+	}
+
+	g.SimulateBoot()
+
+	return nil
+}
+
+// loadLogo writes the scaled up logo tiles and their tilemap to VRAM.
+func (g *Gameboy) loadLogo() {
+	// Each logo byte holds two rows of 4 pixels, both doubled in width and
+	// height to make 8x8 tiles from 4x4 ones.
+	addr := uint16(0x8010) // tile 0 stays blank
+	for _, b := range g.cpu.ROM[rom.HeaderLogo:rom.HeaderLogoEnd] {
+		for _, nibble := range []byte{b >> 4, b & 0x0F} {
+			row := doubleBits(nibble)
+			for i := 0; i < 2; i++ {
+				g.ppu.WriteVRAM(addr, row)
+				g.ppu.WriteVRAM(addr+1, row)
+				addr += 2
+			}
+		}
+	}
+
+	for _, row := range registeredTile {
+		g.ppu.WriteVRAM(addr, row)
+		g.ppu.WriteVRAM(addr+1, row)
+		addr += 2
+	}
+
+	// 12 tiles per row on two rows, the ® follows the first one.
+	for i := uint16(0); i < 12; i++ {
+		g.ppu.WriteVRAM(0x9904+i, byte(1+i))
+		g.ppu.WriteVRAM(0x9924+i, byte(13+i))
+	}
+	g.ppu.WriteVRAM(0x9910, 25)
+}
+
+// doubleBits turns the 4 bits of a nibble into 8 bits, each bit twice.
+func doubleBits(nibble byte) byte {
+	var v byte
+	for i := uint(0); i < 4; i++ {
+		if nibble&(1<<i) != 0 {
+			v |= 0x03 << (i * 2)
+		}
+	}
+
+	return v
+}
+
+// closed returns true if the user closed the debugger.
+func (g *Gameboy) closed() bool {
+	return g.debugger != nil && g.debugger.Closed()
+}
@@ -0,0 +1,181 @@
+// Package cheat decodes Game Genie and GameShark codes.
+package cheat
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Kind is the device a code is meant for.
+type Kind int
+
+const (
+	// GameGenie codes replace bytes read from ROM.
+	GameGenie = Kind(iota)
+
+	// GameShark codes write to RAM once per frame.
+	GameShark
+)
+
+func (k Kind) String() string {
+	if k == GameShark {
+		return "GameShark"
+	}
+
+	return "Game Genie"
+}
+
+// Cheat is a single decoded code.
+type Cheat struct {
+	Code string
+	Name string
+	Kind Kind
+
+	Address uint16
+	Value   byte
+
+	// Game Genie codes with a compare byte only replace the ROM byte if it
+	// has this value, so they only apply to the right bank.
+	Compare    byte
+	HasCompare bool
+
+	// Type is the first byte of GameShark codes, usually 01.
+	Type byte
+
+	// Toggled from the debugger while the emulator runs.
+	disabled int32
+}
+
+// Enabled returns true if the cheat is applied.
+func (c *Cheat) Enabled() bool {
+	return atomic.LoadInt32(&c.disabled) == 0
+}
+
+// SetEnabled turns the cheat on or off.
+func (c *Cheat) SetEnabled(enabled bool) {
+	var v int32
+	if !enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&c.disabled, v)
+}
+
+func (c *Cheat) String() string {
+	state := "on"
+	if !c.Enabled() {
+		state = "off"
+	}
+
+	return fmt.Sprintf("%-11s %-3s %s", c.Code, state, c.Name)
+}
+
+// Parse decodes a Game Genie code (ABC-DEF or ABC-DEF-GHI) or a GameShark
+// code (TTVVLLHH).
+func Parse(code string) (*Cheat, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	digits := strings.Replace(code, "-", "", -1)
+
+	n := make([]byte, len(digits))
+	for i := range digits {
+		v, err := strconv.ParseUint(digits[i:i+1], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cheat code %q", code)
+		}
+		n[i] = byte(v)
+	}
+
+	switch len(n) {
+	case 6, 9:
+		return parseGameGenie(code, n), nil
+	case 8:
+		return parseGameShark(code, n), nil
+	}
+
+	return nil, fmt.Errorf("invalid cheat code %q, expected ABC-DEF-GHI or 01VVAAAA", code)
+}
+
+// parseGameGenie decodes the nibbles of a Game Genie code. The address is
+// scrambled as FCDE ^ F000 and the compare byte is rotated right twice and
+// XORed with BA, H is unused.
+func parseGameGenie(code string, n []byte) *Cheat {
+	c := Cheat{
+		Code:    code,
+		Kind:    GameGenie,
+		Value:   n[0]<<4 | n[1],
+		Address: (uint16(n[5])<<12 | uint16(n[2])<<8 | uint16(n[3])<<4 | uint16(n[4])) ^ 0xF000,
+	}
+
+	if len(n) == 9 {
+		v := n[6]<<4 | n[8]
+		c.Compare = (v>>2 | v<<6) ^ 0xBA
+		c.HasCompare = true
+	}
+
+	return &c
+}
+
+// parseGameShark decodes a TTVVLLHH code, the address is little-endian.
+func parseGameShark(code string, n []byte) *Cheat {
+	return &Cheat{
+		Code:    code,
+		Kind:    GameShark,
+		Type:    n[0]<<4 | n[1],
+		Value:   n[2]<<4 | n[3],
+		Address: uint16(n[6])<<12 | uint16(n[7])<<8 | uint16(n[4])<<4 | uint16(n[5]),
+	}
+}
+
+// List is a set of cheats, a nil List applies no cheat.
+type List []*Cheat
+
+// PatchROM returns the value to read at a ROM address, replaced by the
+// enabled Game Genie codes.
+func (l List) PatchROM(addr uint16, v byte) byte {
+	for _, c := range l {
+		if c.Kind != GameGenie || c.Address != addr || !c.Enabled() {
+			continue
+		}
+
+		if !c.HasCompare || c.Compare == v {
+			return c.Value
+		}
+	}
+
+	return v
+}
+
+// Load reads a cheat file, one code per line optionally followed by a
+// description. Lines starting with # are ignored.
+func Load(path string) (List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var l List
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, " ", 2)
+		c, err := Parse(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		if len(fields) > 1 {
+			c.Name = strings.TrimSpace(fields[1])
+		}
+
+		l = append(l, c)
+	}
+
+	return l, scanner.Err()
+}
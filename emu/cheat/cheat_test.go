@@ -0,0 +1,52 @@
+package cheat
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		code       string
+		kind       Kind
+		addr       uint16
+		value      byte
+		compare    byte
+		hasCompare bool
+	}{
+		{"00A-17B-C49", GameGenie, 0x4A17, 0x00, 0xC8, true},
+		{"3EA-6AF", GameGenie, 0x0A6A, 0x3E, 0, false},
+		{"01FF34C1", GameShark, 0xC134, 0xFF, 0, false},
+	}
+
+	for _, v := range tests {
+		c, err := Parse(v.code)
+		if err != nil {
+			t.Errorf("%s: %s", v.code, err)
+			continue
+		}
+
+		if c.Kind != v.kind || c.Address != v.addr || c.Value != v.value ||
+			c.HasCompare != v.hasCompare || c.Compare != v.compare {
+			t.Errorf("%s: unexpected %+v", v.code, c)
+		}
+	}
+
+	if _, err := Parse("XYZ-123"); err == nil {
+		t.Error("expected an error on an invalid code")
+	}
+}
+
+func TestPatchROM(t *testing.T) {
+	c, _ := Parse("00A-17B-C49")
+	l := List{c}
+
+	if v := l.PatchROM(0x4A17, 0xC8); v != 0x00 {
+		t.Errorf("expected the code to apply, got %02X", v)
+	}
+	if v := l.PatchROM(0x4A17, 0x12); v != 0x12 {
+		t.Errorf("expected the compare byte to prevent the code, got %02X", v)
+	}
+
+	c.SetEnabled(false)
+	if v := l.PatchROM(0x4A17, 0xC8); v != 0xC8 {
+		t.Errorf("expected a disabled code to be ignored, got %02X", v)
+	}
+}
@@ -0,0 +1,226 @@
+package cpu
+
+import (
+	"github.com/L-P/poussin/emu/ppu"
+	"github.com/L-P/poussin/emu/rom"
+)
+
+const (
+	// IOKEY0 CGB mode select, only writable by the boot ROM
+	IOKEY0 = 0xFF4C
+
+	// IOKEY1 CGB speed switch
+	IOKEY1 = 0xFF4D
+
+	// IOHDMA1 CGB VRAM DMA source high
+	IOHDMA1 = 0xFF51
+
+	// IOHDMA2 CGB VRAM DMA source low
+	IOHDMA2 = 0xFF52
+
+	// IOHDMA3 CGB VRAM DMA destination high
+	IOHDMA3 = 0xFF53
+
+	// IOHDMA4 CGB VRAM DMA destination low
+	IOHDMA4 = 0xFF54
+
+	// IOHDMA5 CGB VRAM DMA length/mode/start
+	IOHDMA5 = 0xFF55
+
+	// IOSVBK CGB WRAM bank
+	IOSVBK = 0xFF70
+)
+
+const (
+	// KEY0DMGCompat is set by the boot ROM to disable CGB features.
+	KEY0DMGCompat = 1 << 2
+
+	// KEY1Prepare arms the speed switch that happens on the next STOP.
+	KEY1Prepare = 1 << 0
+
+	// HDMA5HBlank selects a 16 bytes transfer per HBlank instead of a
+	// general purpose transfer.
+	HDMA5HBlank = 1 << 7
+
+	hdmaBlockSize = 0x10
+)
+
+// VRAMDMA holds the state of the CGB VRAM DMA (HDMA1-5).
+type VRAMDMA struct {
+	Src, Dst uint16
+
+	// Active is set while an HBlank transfer is running.
+	Active bool
+
+	// Remaining number of 16 bytes blocks to copy.
+	Remaining int
+}
+
+// IsCGBIO returns true if the address is a CPU-side CGB register.
+func IsCGBIO(addr uint16) bool {
+	return addr == IOKEY0 || addr == IOKEY1 || addr == IOSVBK ||
+		(addr >= IOHDMA1 && addr <= IOHDMA5)
+}
+
+// setCGBMode enables or disables the CGB features, they are disabled when
+// running DMG games on CGB hardware.
+func (c *CPU) setCGBMode(enabled bool) {
+	c.CGBMode = c.CGB && enabled
+	c.PPU.SetCGB(c.CGB, c.CGBMode)
+	if c.CGBMode {
+		return
+	}
+
+	c.switchWRAMBank(1)
+	if c.CGB && c.CompatPalettesOverride != nil {
+		c.PPU.SetCompatPalettes(*c.CompatPalettesOverride)
+	}
+}
+
+// switchWRAMBank maps the given WRAM bank to D000-DFFF. Mem always holds the
+// current bank so everything else can keep reading it directly.
+func (c *CPU) switchWRAMBank(bank int) {
+	if bank == 0 {
+		bank = 1
+	}
+	if c.WRAMBank == 0 { // zero value, bank 1 is mapped after reset
+		c.WRAMBank = 1
+	}
+	if bank == c.WRAMBank {
+		return
+	}
+
+	copy(c.WRAMBanks[c.WRAMBank][:], c.Mem[0xD000:0xE000])
+	copy(c.Mem[0xD000:0xE000], c.WRAMBanks[bank][:])
+	c.WRAMBank = bank
+}
+
+func (c *CPU) fetchCGBIO(addr uint16) byte {
+	if !c.CGBMode {
+		return 0xFF
+	}
+
+	switch addr {
+	case IOKEY0:
+		return c.Mem[IOKEY0]
+	case IOKEY1:
+		var speed byte
+		if c.DoubleSpeed {
+			speed = 1 << 7
+		}
+		return 0x7E | speed | (c.Mem[IOKEY1] & KEY1Prepare)
+	case IOSVBK:
+		return 0xF8 | byte(c.WRAMBank)
+	case IOHDMA5:
+		remaining := byte(c.HDMA.Remaining-1) & 0x7F
+		if c.HDMA.Active {
+			return remaining
+		}
+		return 0x80 | remaining
+	}
+
+	return 0xFF // HDMA1-4 are write only
+}
+
+func (c *CPU) writeCGBIO(addr uint16, value byte) {
+	// KEY0 is locked once the boot ROM is done.
+	if addr == IOKEY0 && c.CGB && c.Mem[IODisableBootROM] == 0 {
+		c.Mem[IOKEY0] = value
+		return
+	}
+
+	if !c.CGBMode {
+		return
+	}
+
+	switch addr {
+	case IOKEY1:
+		c.Mem[IOKEY1] = value & KEY1Prepare
+	case IOSVBK:
+		c.switchWRAMBank(int(value & 0x07))
+	case IOHDMA1:
+		c.HDMA.Src = (c.HDMA.Src & 0x00FF) | uint16(value)<<8
+	case IOHDMA2:
+		c.HDMA.Src = (c.HDMA.Src & 0xFF00) | uint16(value&0xF0)
+	case IOHDMA3:
+		c.HDMA.Dst = (c.HDMA.Dst & 0x00FF) | uint16(value&0x1F)<<8
+	case IOHDMA4:
+		c.HDMA.Dst = (c.HDMA.Dst & 0xFF00) | uint16(value&0xF0)
+	case IOHDMA5:
+		c.writeHDMA5(value)
+	}
+}
+
+// writeHDMA5 starts, or cancels, a VRAM DMA.
+func (c *CPU) writeHDMA5(value byte) {
+	if c.HDMA.Active && value&HDMA5HBlank == 0 {
+		c.HDMA.Active = false
+		return
+	}
+
+	c.HDMA.Remaining = int(value&0x7F) + 1
+	if value&HDMA5HBlank == HDMA5HBlank {
+		c.HDMA.Active = true
+		return
+	}
+
+	// General purpose DMA, the copy is instant like OAM DMA.
+	for c.HDMA.Remaining > 0 {
+		c.copyHDMABlock()
+	}
+}
+
+// updateHDMA copies a block of an HBlank DMA when the PPU entered HBlank.
+func (c *CPU) updateHDMA() {
+	if !c.PPU.HBlankStarted {
+		return
+	}
+	c.PPU.HBlankStarted = false
+
+	if !c.HDMA.Active || c.Halted {
+		return
+	}
+
+	c.copyHDMABlock()
+	if c.HDMA.Remaining == 0 {
+		c.HDMA.Active = false
+	}
+}
+
+func (c *CPU) copyHDMABlock() {
+	for i := uint16(0); i < hdmaBlockSize; i++ {
+		dst := 0x8000 | ((c.HDMA.Dst + i) & 0x1FFF)
+		c.PPU.WriteVRAM(dst, c.fetch(c.HDMA.Src+i))
+	}
+
+	c.HDMA.Src += hdmaBlockSize
+	c.HDMA.Dst = (c.HDMA.Dst + hdmaBlockSize) & 0x1FF0
+	c.HDMA.Remaining--
+}
+
+// simulateCGBBoot sets the mode the CGB boot ROM would pick from the header.
+func (c *CPU) simulateCGBBoot() {
+	c.Mem[IOKEY0] = c.ROM[rom.HeaderCGBFlag]
+	if c.ROM[rom.HeaderCGBFlag]&0x80 == 0 {
+		c.Mem[IOKEY0] = KEY0DMGCompat
+		c.DE = 0x0008
+		c.HL = 0x007C
+		c.InternalDIV = 0x267C // picking a palette takes a while
+
+		// Only the title and licensee are used, sizes do not matter.
+		h, _ := rom.NewHeader(c.ROM[:])
+		c.PPU.SetCompatPalettes(ppu.AutoCompatPalettes(h))
+	}
+}
+
+// switchSpeed toggles double speed mode if it was armed with KEY1, it is
+// called by STOP.
+func (c *CPU) switchSpeed() {
+	if !c.CGBMode || c.Mem[IOKEY1]&KEY1Prepare == 0 {
+		return
+	}
+
+	c.DoubleSpeed = !c.DoubleSpeed
+	c.Mem[IOKEY1] = 0
+	c.InternalDIV = 0
+}
@@ -0,0 +1,140 @@
+package cpu
+
+import (
+	"testing"
+)
+
+func newTestCGB(t *testing.T) *CPU {
+	t.Helper()
+
+	c := newTestCPU(t)
+	c.SetModel(ModelCGB)
+
+	return c
+}
+
+func TestWRAMBanks(t *testing.T) {
+	c := newTestCGB(t)
+	c.Write(0xC000, 0x01)
+	c.Write(0xD000, 0x11)
+
+	c.Write(IOSVBK, 2)
+	if actual := c.Fetch(0xD000); actual != 0x00 {
+		t.Errorf("expected bank 2 to be empty, got %02X", actual)
+	}
+	c.Write(0xD000, 0x22)
+
+	cases := []struct {
+		svbk, bank byte
+		expected   byte
+	}{
+		{0, 1, 0x11},
+		{2, 2, 0x22},
+		{1, 1, 0x11},
+		{0xFA, 2, 0x22}, // only the lower 3 bits are used
+	}
+	for _, v := range cases {
+		c.Write(IOSVBK, v.svbk)
+		if actual := c.Fetch(IOSVBK); actual != 0xF8|v.bank {
+			t.Errorf("SVBK=%02X: expected bank %d to be mapped, got %02X", v.svbk, v.bank, actual)
+		}
+		if actual := c.Fetch(0xD000); actual != v.expected {
+			t.Errorf("SVBK=%02X: expected %02X, got %02X", v.svbk, v.expected, actual)
+		}
+		if actual := c.Fetch(0xF000); actual != v.expected {
+			t.Errorf("SVBK=%02X: expected echo RAM to mirror the bank, got %02X", v.svbk, actual)
+		}
+		if actual := c.Fetch(0xC000); actual != 0x01 {
+			t.Errorf("SVBK=%02X: expected bank 0 to stay mapped, got %02X", v.svbk, actual)
+		}
+	}
+}
+
+// startHDMA copies 0x00-0x3F from C000 to 8000 in the given mode.
+func startHDMA(c *CPU, hdma5 byte) {
+	for i := uint16(0); i < 0x40; i++ {
+		c.Write(0xC000+i, byte(i))
+	}
+
+	c.Write(IOHDMA1, 0xC0)
+	c.Write(IOHDMA2, 0x00)
+	c.Write(IOHDMA3, 0x80)
+	c.Write(IOHDMA4, 0x00)
+	c.Write(IOHDMA5, hdma5)
+}
+
+// copiedHDMA returns the number of bytes that were copied to VRAM.
+func copiedHDMA(c *CPU) int {
+	for i := uint16(0); i < 0x40; i++ {
+		if c.PPU.FetchVRAM(0x8000+i) != byte(i) {
+			return int(i)
+		}
+	}
+
+	return 0x40
+}
+
+func TestGeneralPurposeDMA(t *testing.T) {
+	c := newTestCGB(t)
+	c.Write(0x8000, 0xFF) // so the first byte differs before the copy
+	startHDMA(c, 0x01)
+
+	if actual := copiedHDMA(c); actual != 0x20 {
+		t.Errorf("expected GDMA to copy 2 blocks at once, copied %d bytes", actual)
+	}
+	if actual := c.Fetch(IOHDMA5); actual != 0xFF {
+		t.Errorf("expected HDMA5 to read FF once done, got %02X", actual)
+	}
+}
+
+func TestHBlankDMA(t *testing.T) {
+	c := newTestCGB(t)
+	c.Write(0x8000, 0xFF)
+	startHDMA(c, HDMA5HBlank|0x01)
+
+	if actual := copiedHDMA(c); actual != 0 {
+		t.Errorf("expected nothing to be copied before HBlank, copied %d bytes", actual)
+	}
+	if actual := c.Fetch(IOHDMA5); actual != 0x01 {
+		t.Errorf("expected HDMA5 to read 01 while active, got %02X", actual)
+	}
+
+	for i := 1; i <= 2; i++ {
+		c.PPU.HBlankStarted = true
+		step(t, c)
+		if actual := copiedHDMA(c); actual != i*hdmaBlockSize {
+			t.Errorf("HBlank %d: expected %d bytes copied, got %d", i, i*hdmaBlockSize, actual)
+		}
+	}
+	if c.HDMA.Active || c.Fetch(IOHDMA5) != 0xFF {
+		t.Errorf("expected the transfer to be done, HDMA5=%02X", c.Fetch(IOHDMA5))
+	}
+
+	c.PPU.HBlankStarted = true
+	step(t, c)
+	if actual := copiedHDMA(c); actual != 0x20 {
+		t.Errorf("expected the transfer to stop after 2 blocks, copied %d bytes", actual)
+	}
+}
+
+func TestSpeedSwitch(t *testing.T) {
+	c := newTestCGB(t)
+	copy(c.ROM[0x150:], []byte{0x10, 0x00, 0x10, 0x00}) // STOP, STOP
+
+	step(t, c)
+	if c.DoubleSpeed {
+		t.Fatal("expected STOP not to switch speed without KEY1 armed")
+	}
+
+	c.Write(IOKEY1, KEY1Prepare)
+	if actual := c.Fetch(IOKEY1); actual != 0x7F {
+		t.Errorf("expected KEY1 to read 7F once armed, got %02X", actual)
+	}
+	step(t, c)
+	if !c.DoubleSpeed {
+		t.Fatal("expected STOP to switch to double speed")
+	}
+	if actual := c.Fetch(IOKEY1); actual != 0xFE {
+		t.Errorf("expected KEY1 to read FE in double speed, got %02X", actual)
+	}
+}
@@ -0,0 +1,29 @@
+package cpu
+
+import "github.com/L-P/poussin/emu/cheat"
+
+// gameSharkWRAMBank is the GameShark code type selecting the CGB WRAM bank
+// in its low bits.
+const gameSharkWRAMBank = 0x90
+
+// applyGameShark writes the GameShark values to RAM, the device does it at
+// every VBlank.
+func (c *CPU) applyGameShark() {
+	for _, v := range c.Cheats {
+		if v.Kind != cheat.GameShark || !v.Enabled() {
+			continue
+		}
+
+		// Unmapped banks are written directly, the mapped one is in Mem.
+		bank := int(v.Type & 0x07)
+		if bank == 0 {
+			bank = 1
+		}
+		if c.CGB && v.Type&0xF0 == gameSharkWRAMBank && AddrToMemType(v.Address) == WRAMX && bank != c.WRAMBank {
+			c.WRAMBanks[bank][v.Address-0xD000] = v.Value
+			continue
+		}
+
+		c.write(v.Address, v.Value)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/L-P/poussin/emu/cheat"
 	"github.com/L-P/poussin/emu/ppu"
 	"github.com/L-P/poussin/emu/rom"
+	"github.com/L-P/poussin/emu/sgb"
 )
 
 // CPU is a Sharp LR35902 emulator.
@@ -21,12 +23,50 @@ type CPU struct {
 	// lacks VRAM, ROM0/ROMX, mirrored memory, etc.
 	Mem [0xFFFF]byte
 
-	// Boot holds the bootstrap ROM mapped to 0x0000-0x00FF on DMG
-	Boot [256]byte
+	// Boot holds the bootstrap ROM mapped to 0x0000-0x00FF on DMG, the CGB
+	// one is also mapped to 0x0200-0x08FF
+	Boot [0x900]byte
 
 	// ROM holds the ROM mapped to ROM0/ROMX
 	ROM [1024 * 1024 * 8]byte // Per Wikipedia, a GB ROM is 8 MiB max
 
+	// Model is the emulated hardware.
+	Model Model
+
+	// {{{ CGB
+	// CGB is set when emulating Game Boy Color hardware.
+	CGB bool
+
+	// CGBMode is set when CGB features are enabled, it is unset when running
+	// a DMG game on CGB hardware.
+	CGBMode bool
+
+	// DoubleSpeed is set when the CPU runs at 8 MHz, toggled by STOP.
+	DoubleSpeed bool
+
+	// WRAMBank is the WRAM bank mapped to D000-DFFF (SVBK).
+	WRAMBank int
+
+	// WRAMBanks holds the WRAM banks that are not mapped, the mapped one lives
+	// in Mem.
+	WRAMBanks [8][0x1000]byte
+
+	// HDMA is the VRAM DMA state.
+	HDMA VRAMDMA
+
+	// CompatPalettesOverride replaces the palettes picked by the boot ROM
+	// when running a DMG game on CGB hardware.
+	CompatPalettesOverride *ppu.CompatPalettes
+	// }}} CGB
+
+	// SGB is the Super Game Boy listening to the joypad register, nil when
+	// not emulating one.
+	SGB *sgb.SGB
+
+	// Cheats are the Game Genie and GameShark codes, nil when none are
+	// loaded.
+	Cheats cheat.List
+
 	// Halted is set by the HALT instruction, it can only be reset by interrupts.
 	Halted bool
 
@@ -40,14 +80,23 @@ type CPU struct {
 	InCycle bool
 
 	// MemIOBuffer contains all memory reads/writes (except for instruction
-	// fetching) since it was last cleared. It holds six bytes per fetch/write,
-	// two for the current PC, one for read (0x01) or write (0x02), two for the
-	// address of the read (little-endian), and one for the value.
+	// fetching) since it was last cleared. It holds seven bytes per
+	// fetch/write, two for the PC of the instruction doing the access (the
+	// interrupted PC for interrupt pushes), one for read (0x01) or write
+	// (0x02), two for the address of the read (little-endian), one for the
+	// previous value, and one for the value.
 	MemIOBuffer bytes.Buffer
 
 	// LastOpcode is the last opcode executed by the CPU
 	LastOpcode byte
 
+	// accessPC is the PC logged in MemIOBuffer, PC already points to the
+	// next instruction when an instruction accesses memory.
+	accessPC uint16
+
+	// LastPC is the address of the last opcode executed by the CPU
+	LastPC uint16
+
 	// LastCycleWasInterrupt is set to true right after an interrupt was triggered
 	LastCycleWasInterrupt bool
 
@@ -91,6 +140,13 @@ type CPU struct {
 	// register.
 	InternalDIV uint16
 
+	// Serial is the link port controller (SB, SC).
+	Serial Serial
+
+	// LastSerialUpdateCycle is set with the current Cycle when we update the
+	// serial controller.
+	LastSerialUpdateCycle int
+
 	Joypad      JoypadState
 	JoypadInput <-chan JoypadState
 }
@@ -99,6 +155,7 @@ type CPU struct {
 // don't want to send inputs.
 func New(ppu *ppu.PPU, input <-chan JoypadState, debug bool) CPU {
 	c := CPU{
+		Model:       ModelDMG,
 		PPU:         ppu,
 		EnableDebug: debug,
 		JoypadInput: input,
@@ -115,19 +172,19 @@ func (c *CPU) Reset() {
 	c.WriteIF(0)
 	c.WriteIE(0)
 	c.WriteTAC(0)
+	c.Serial.WriteSC(0)
 }
 
-// SimulateBoot puts the CPU in the same state it would be after running the Nintendo boot ROM.
+// SimulateBoot puts the CPU in the same state it would be after running the
+// boot ROM of the current model. The ROM must be loaded first as some boot
+// ROMs read the header.
 func (c *CPU) SimulateBoot() {
-	c.A = 0x01
-	c.SetF(0xB0)
-	c.BC = 0x0013
-	c.DE = 0x00D8
-	c.HL = 0x014D
-	c.PC = 0x0100
-	c.SP = 0xFFFE
+	c.applyPostBootState()
+	if c.CGB {
+		c.simulateCGBBoot()
+	}
 
-	c.WriteIF(0)
+	c.WriteIF(IEVBlank)
 	c.WriteIE(0)
 	c.WriteTAC(0)
 	c.InterruptMaster = false
@@ -136,9 +193,16 @@ func (c *CPU) SimulateBoot() {
 
 // Step runs the next CPU instruction.
 func (c *CPU) Step() (int, error) {
+	if c.PPU.InterruptVBlank {
+		c.SetIF(IEVBlank)
+		c.PPU.InterruptVBlank = false
+		c.applyGameShark()
+	}
+	c.updateHDMA()
 	c.updateJoypad()
 	c.Jumped = false
 	defer c.UpdateTimers()
+	defer c.UpdateSerial()
 
 	c.LastCycleWasInterrupt = false
 	if cycles := c.CheckInterrupts(); cycles > 0 {
@@ -154,6 +218,8 @@ func (c *CPU) Step() (int, error) {
 		return 4, nil
 	}
 
+	c.LastPC = c.PC
+	c.accessPC = c.PC
 	opcode := c.Fetch(c.PC)
 	cb := opcode == 0xCB
 	if cb {
@@ -212,10 +278,16 @@ func (c *CPU) Execute(ins Instruction, l, h byte) (int, error) {
 	return int(ins.Cycles), nil
 }
 
-// LoadBootROM puts a boot rom in the 256 first bytes or RAM.
+// LoadBootROM puts a boot rom in the 256 first bytes or RAM, CGB boot ROMs
+// are 0x900 bytes long.
 func (c *CPU) LoadBootROM(data []byte) error {
-	if count := copy(c.Boot[:], data); count != 256 {
-		return fmt.Errorf("did not copy 256 bytes: %d", count)
+	size := 256
+	if c.CGB {
+		size = len(c.Boot)
+	}
+
+	if count := copy(c.Boot[:], data); count != size {
+		return fmt.Errorf("did not copy %d bytes: %d", size, count)
 	}
 
 	return nil
@@ -223,12 +295,18 @@ func (c *CPU) LoadBootROM(data []byte) error {
 
 // LoadROM loads a ROM in RAM.
 func (c *CPU) LoadROM(data []byte) error {
-	copy(c.ROM[:], data)
+	if len(data) > len(c.ROM) {
+		return fmt.Errorf("ROM too large: %d bytes", len(data))
+	}
+
+	// Homebrew and test ROMs often have nonstandard size codes, they run
+	// fine so the error is left for the caller to report.
+	h, _ := rom.NewHeader(data)
 
-	h := rom.NewHeader(data)
-	if h.CGBOnly {
-		return errors.New("only DMG games are supported")
+	if h.CGBOnly && !c.CGB {
+		return errors.New("CGB-only games need Game Boy Color hardware")
 	}
+	copy(c.ROM[:], data)
 
 	return nil
 }
@@ -237,6 +315,7 @@ func (c *CPU) LoadROM(data []byte) error {
 // program counter to the stack.
 func (c *CPU) DoInterrupt(addr uint16) int {
 	c.InCycle = true
+	c.accessPC = c.PC
 	c.StackPush16b(c.PC)
 	c.PC = addr
 	c.InCycle = false
@@ -252,11 +331,6 @@ func (c *CPU) CheckInterrupts() int {
 		return 0
 	}
 
-	if c.PPU.InterruptVBlank {
-		c.SetIF(IEVBlank)
-		c.PPU.InterruptVBlank = false
-	}
-
 	if c.IFIsSet(IETimer) && c.IEEnabled(IETimer) {
 		c.Mem[IOTIMA] = c.Mem[IOTMA]
 		if c.InterruptMaster {
@@ -275,6 +349,14 @@ func (c *CPU) CheckInterrupts() int {
 		return c.DoInterrupt(0x0040)
 	}
 
+	if c.IFIsSet(IESerial) && c.IEEnabled(IESerial) {
+		if c.InterruptMaster {
+			c.UnSetIF(IESerial)
+		}
+
+		return c.DoInterrupt(0x0058)
+	}
+
 	if c.IFIsSet(IEJoypad) {
 		c.UnSetIF(IEJoypad)
 		return c.DoInterrupt(0x0060)
@@ -0,0 +1,88 @@
+package cpu
+
+import (
+	"image"
+	"testing"
+
+	"github.com/L-P/poussin/emu/ppu"
+	"github.com/L-P/poussin/emu/rom"
+)
+
+// newTestCPU returns a DMG CPU running NOPs from $0150 with the boot ROM
+// unmapped.
+func newTestCPU(t *testing.T) *CPU {
+	t.Helper()
+
+	c := New(ppu.New(make(chan *image.RGBA, 1)), nil, false)
+	c.Mem[IODisableBootROM] = 1
+	c.PC = 0x150
+
+	return &c
+}
+
+// step runs one instruction and fails the test on error.
+func step(t *testing.T, c *CPU) int {
+	t.Helper()
+
+	cycles, err := c.Step()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cycles
+}
+
+func TestLoadROMNonstandardSizes(t *testing.T) {
+	data := make([]byte, 0x8000)
+	data[rom.HeaderROMSize] = 0x42
+	data[rom.HeaderRAMSize] = 0x42
+
+	c := newTestCPU(t)
+	if err := c.LoadROM(data); err != nil {
+		t.Errorf("expected nonstandard size codes to be accepted, got %s", err)
+	}
+
+	data[rom.HeaderCGBFlag] = 0xC0
+	c = newTestCPU(t)
+	if err := c.LoadROM(data); err == nil {
+		t.Error("expected a CGB-only ROM to be refused on DMG")
+	}
+	if c.ROM[rom.HeaderCGBFlag] != 0 {
+		t.Error("expected a refused ROM not to be loaded")
+	}
+}
+
+func TestSubHL(t *testing.T) {
+	c := newTestCPU(t)
+	copy(c.ROM[0x150:], []byte{0x96, 0x3C}) // SUB (HL), INC A
+	c.A = 3
+	c.HL = 0xC000
+	c.Mem[0xC000] = 1
+
+	if cycles := step(t, c); cycles != 8 {
+		t.Errorf("expected SUB (HL) to take 8 cycles, took %d", cycles)
+	}
+	if c.PC != 0x151 || c.A != 2 || !c.FlagSubstract {
+		t.Errorf("expected PC=0151 A=02 after SUB (HL), got PC=%04X A=%02X", c.PC, c.A)
+	}
+
+	step(t, c)
+	if c.PC != 0x152 || c.A != 3 {
+		t.Errorf("expected the next instruction to run, got PC=%04X A=%02X", c.PC, c.A)
+	}
+}
+
+func TestJPC(t *testing.T) {
+	ins := Decode(0xDA, false)
+	if actual := ins.String(0x00, 0x40); actual != "JP C,$4000" {
+		t.Errorf("expected JP C,$4000, got %s", actual)
+	}
+
+	c := newTestCPU(t)
+	copy(c.ROM[0x150:], []byte{0xDA, 0x00, 0x40})
+	c.FlagCarry = true
+	step(t, c)
+	if c.PC != 0x4000 {
+		t.Errorf("expected JP C to jump when carry is set, got PC=%04X", c.PC)
+	}
+}
@@ -40,3 +40,30 @@ func Decode(opcode byte, cb bool) Instruction {
 
 	return Instructions[opcode]
 }
+
+// DecodeAt decodes the instruction at addr, reading memory with fetch. It
+// returns the instruction, its arguments, and its length in bytes including
+// the CB prefix.
+func DecodeAt(fetch func(uint16) byte, addr uint16) (ins Instruction, l, h byte, length uint16) {
+	opcode := fetch(addr)
+	cb := opcode == 0xCB
+	if cb {
+		addr++
+		opcode = fetch(addr)
+		length = 1
+	}
+
+	ins = Decode(opcode, cb)
+	length += uint16(ins.Length)
+	if ins.Length == 0 { // undefined opcode
+		length++
+	}
+	if ins.Length > 1 {
+		l = fetch(addr + 1)
+	}
+	if ins.Length > 2 {
+		h = fetch(addr + 2)
+	}
+
+	return ins, l, h, length
+}
@@ -9,7 +9,7 @@ var instructionsMap = map[byte]Instruction{
 	0x00: {1, 4, "NOP", i_nop},
 	0x10: {2, 4, "STOP %02X", i_stop},
 	0x76: {1, 4, "HALT", i_halt},
-	0x08: {3, 20, "LD (%02X%02X),SP", i_ld_d8_sp},
+	0x08: {3, 20, "LD ($%02X%02X),SP", i_ld_d8_sp},
 	0x3F: {1, 4, "CCF", i_ccf},
 	0x37: {1, 4, "SCF", i_scf},
 
@@ -74,9 +74,9 @@ var instructionsMap = map[byte]Instruction{
 	0x86: {1, 8, "ADD A,(HL)", i_add_a_phl},
 	0x8E: {1, 8, "ADC A,(HL)", i_adc_a_phl},
 	0x9E: {1, 8, "SBC A,(HL)", i_sbc_a_phl},
-	0xA6: {1, 8, "AND A", i_and_phl},
-	0xC6: {2, 8, "ADD A,(%02X)", i_add_a_d8},
-	0xCE: {2, 8, "ADC A,(%02X)", i_adc_a_d8},
+	0xA6: {1, 8, "AND (HL)", i_and_phl},
+	0xC6: {2, 8, "ADD A,$%02X", i_add_a_d8},
+	0xCE: {2, 8, "ADC A,$%02X", i_adc_a_d8},
 
 	0x09: {1, 8, "ADD HL,BC", i_add_hl_nn("BC")},
 	0x19: {1, 8, "ADD HL,DE", i_add_hl_nn("DE")},
@@ -84,8 +84,8 @@ var instructionsMap = map[byte]Instruction{
 	0x39: {1, 8, "ADD HL,SP", i_add_hl_nn("SP")},
 	0xE8: {2, 16, "ADD SP,%02X", i_add_sp_r8},
 
-	0x96: {2, 8, "SUB %02X", i_sub_phl},
-	0xD6: {2, 8, "SUB %02X", i_sub_d8},
+	0x96: {1, 8, "SUB (HL)", i_sub_phl},
+	0xD6: {2, 8, "SUB $%02X", i_sub_d8},
 	0x97: {1, 4, "SUB A", i_sub_n('A')},
 	0x90: {1, 4, "SUB B", i_sub_n('B')},
 	0x91: {1, 4, "SUB C", i_sub_n('C')},
@@ -101,7 +101,7 @@ var instructionsMap = map[byte]Instruction{
 	0x9B: {1, 4, "SBC A,E", i_sbc_a_n('E')},
 	0x9C: {1, 4, "SBC A,H", i_sbc_a_n('H')},
 	0x9D: {1, 4, "SBC A,L", i_sbc_a_n('L')},
-	0xDE: {2, 8, "SBC A,%01X", i_sbc_a_d8},
+	0xDE: {2, 8, "SBC A,$%02X", i_sbc_a_d8},
 
 	0xA8: {1, 4, "XOR B", i_xor_n('B')},
 	0xA9: {1, 4, "XOR C", i_xor_n('C')},
@@ -237,7 +237,7 @@ var instructionsMap = map[byte]Instruction{
 
 	0x22: {1, 8, "LDI (HL),A", i_ldi_phl_a},
 	0x32: {1, 8, "LDD (HL),A", i_ldd_phl_a},
-	0x36: {2, 12, "LD (HL),%02X", i_ld_phl_n},
+	0x36: {2, 12, "LD (HL),$%02X", i_ld_phl_n},
 	0xE0: {2, 12, "LDH ($%02X),A", i_ldh_pn_a},
 	0xF0: {2, 12, "LDH A,($%02X)", i_ldh_a_pn},
 	0xF8: {2, 12, "LDHL SP,$%02X", i_ldhl_sp_r8},
@@ -262,7 +262,7 @@ var instructionsMap = map[byte]Instruction{
 	0xC3: {3, 12, "JP $%02X%02X", i_jp_nn},
 	0xCA: {3, 12, "JP Z,$%02X%02X", i_jp_z},
 	0xC2: {3, 12, "JP NZ,$%02X%02X", i_jp_nz},
-	0xDA: {3, 12, "JP NC,$%02X%02X", i_jp_c},
+	0xDA: {3, 12, "JP C,$%02X%02X", i_jp_c},
 	0xD2: {3, 12, "JP NC,$%02X%02X", i_jp_nc},
 	0xE9: {1, 4, "JP (HL)", i_jp_hl}, // weird mnemonic, we go to HL, not (HL)
 
@@ -290,14 +290,14 @@ var instructionsMap = map[byte]Instruction{
 	0xD4: {3, 24, "CALL NC,$%02X%02X", i_call_nc},
 	0xDC: {3, 24, "CALL C,$%02X%02X", i_call_c},
 
-	0xC7: {1, 16, "RST,$00", i_rst(0x00)},
-	0xCF: {1, 16, "RST,$08", i_rst(0x08)},
-	0xD7: {1, 16, "RST,$10", i_rst(0x10)},
-	0xDF: {1, 16, "RST,$18", i_rst(0x18)},
-	0xE7: {1, 16, "RST,$20", i_rst(0x20)},
-	0xEF: {1, 16, "RST,$28", i_rst(0x28)},
-	0xF7: {1, 16, "RST,$30", i_rst(0x30)},
-	0xFF: {1, 16, "RST,$38", i_rst(0x38)},
+	0xC7: {1, 16, "RST $00", i_rst(0x00)},
+	0xCF: {1, 16, "RST $08", i_rst(0x08)},
+	0xD7: {1, 16, "RST $10", i_rst(0x10)},
+	0xDF: {1, 16, "RST $18", i_rst(0x18)},
+	0xE7: {1, 16, "RST $20", i_rst(0x20)},
+	0xEF: {1, 16, "RST $28", i_rst(0x28)},
+	0xF7: {1, 16, "RST $30", i_rst(0x30)},
+	0xFF: {1, 16, "RST $38", i_rst(0x38)},
 }
 
 var Instructions [0xFF + 1]Instruction
@@ -319,6 +319,7 @@ func i_stop(c *CPU, l, _ byte) {
 		panic(fmt.Errorf("invalid stop: %02X", l))
 	}
 
+	c.switchSpeed()
 	// c.Stopped = true
 }
 
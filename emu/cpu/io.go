@@ -11,6 +11,12 @@ const (
 	// IOSB Serial transfer data
 	IOSB = 0xFF01
 
+	// IOSC Serial transfer control
+	IOSC = 0xFF02
+
+	// IODMA OAM DMA transfer start address (W)
+	IODMA = 0xFF46
+
 	// IODisableBootROM (R/W once)
 	IODisableBootROM = 0xFF50
 
@@ -113,11 +119,19 @@ func (c *CPU) FetchIO(addr uint16) byte {
 		return c.PPU.Fetch(addr)
 	}
 
+	if IsCGBIO(addr) {
+		return c.fetchCGBIO(addr)
+	}
+
 	switch addr {
 	case IODisableBootROM:
 		return c.Mem[IODisableBootROM]
 	case IOP1:
 		return c.FetchIOP1()
+	case IOSB:
+		return c.Serial.SB
+	case IOSC:
+		return c.Serial.FetchSC()
 	case IOIF:
 		return c.FetchIF()
 	case IODIV:
@@ -134,6 +148,10 @@ func (c *CPU) FetchIO(addr uint16) byte {
 func (c *CPU) WriteIOP1(b byte) {
 	const mask byte = 0x30
 	c.Mem[IOP1] = (c.Mem[IOP1] &^ mask) | (b & mask) | 0xC0
+
+	if c.SGB != nil {
+		c.SGB.WriteP1(b)
+	}
 }
 
 // FetchIOP1 returns the joypad register state.
@@ -145,6 +163,17 @@ func (c *CPU) FetchIOP1() byte {
 		b3 byte = 1
 	)
 
+	// The SGB answers with the joypad ID when nothing is selected, only the
+	// first joypad is plugged.
+	if c.SGB != nil {
+		if c.Mem[IOP1]&0x30 == 0x30 {
+			return 0xF0 | c.SGB.JoypadID()
+		}
+		if c.SGB.Player() != 0 {
+			return 0xC0 | (c.Mem[IOP1] & 0xF0) | 0x0F
+		}
+	}
+
 	// Buttons
 	if c.Mem[IOP1]&(1<<4) == 0 {
 		if c.Joypad.A {
@@ -182,11 +211,20 @@ func (c *CPU) FetchIOP1() byte {
 
 // WriteIO writes a byte to a hardware register.
 func (c *CPU) WriteIO(addr uint16, value byte) {
+	if addr == IODMA {
+		c.DMA(value)
+	}
+
 	if ppu.IsPPUIO(addr) {
 		c.PPU.Write(addr, value)
 		return
 	}
 
+	if IsCGBIO(addr) {
+		c.writeCGBIO(addr, value)
+		return
+	}
+
 	switch addr {
 	case IODIV:
 		c.InternalDIV = 0
@@ -194,8 +232,13 @@ func (c *CPU) WriteIO(addr uint16, value byte) {
 		if c.EnableDebug {
 			c.SBBuffer.WriteByte(value)
 		}
-		c.Mem[IOSB] = value
+		c.Serial.SB = value
+	case IOSC:
+		c.Serial.WriteSC(value)
 	case IODisableBootROM:
+		if c.Mem[IODisableBootROM] == 0 && c.CGB {
+			c.setCGBMode(c.Mem[IOKEY0]&KEY0DMGCompat == 0)
+		}
 		c.Mem[IODisableBootROM] = 1 // Boot ROM can never be re-enabled
 	case IOIF:
 		c.WriteIF(value)
@@ -209,6 +252,15 @@ func (c *CPU) WriteIO(addr uint16, value byte) {
 	}
 }
 
+// DMA copies 160 bytes from XX00-XX9F to OAM where XX is the given value.
+// The copy is instant, the CPU is not blocked for the 160 µs it should take.
+func (c *CPU) DMA(value byte) {
+	src := uint16(value) << 8
+	for i := uint16(0); i < 0xA0; i++ {
+		c.PPU.WriteOAM(0xFE00+i, c.fetch(src+i))
+	}
+}
+
 // WriteIF writes the interrupt flag value.
 func (c *CPU) WriteIF(value byte) {
 	c.Mem[IOIF] = 0xE0 | value
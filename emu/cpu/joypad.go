@@ -16,13 +16,21 @@ type JoypadState struct {
 func (c *CPU) updateJoypad() {
 	select {
 	case curState := <-c.JoypadInput:
-		if curState != c.Joypad {
-			c.Joypad = curState
-			p1 := c.FetchIOP1()
-			if p1&(1<<5|1<<4) == 0 && c.IEEnabled(IEJoypad) {
-				c.SetIF(IEJoypad)
-			}
-		}
+		c.SetJoypad(curState)
 	default:
 	}
 }
+
+// SetJoypad changes the state of the joypad buttons and requests the joypad
+// interrupt if needed.
+func (c *CPU) SetJoypad(state JoypadState) {
+	if state == c.Joypad {
+		return
+	}
+
+	c.Joypad = state
+	p1 := c.FetchIOP1()
+	if p1&(1<<5|1<<4) == 0 && c.IEEnabled(IEJoypad) {
+		c.SetIF(IEJoypad)
+	}
+}
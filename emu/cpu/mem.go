@@ -2,29 +2,16 @@ package cpu
 
 // Fetch reads a byte from mapped memory
 func (c *CPU) Fetch(addr uint16) byte {
-	var v byte
-	switch AddrToMemType(addr) {
-	case ROM0:
-		v = c.FetchROM0(addr)
-	case ROMX:
-		v = c.FetchROMX(addr)
-	case VRAM:
-		v = c.PPU.Fetch(addr)
-	case IO:
-		v = c.FetchIO(addr)
-	case IERegister:
-		v = c.FetchIE()
-	default:
-		v = c.Mem[addr]
-	}
+	v := c.fetch(addr)
 
 	if c.EnableDebug && c.InCycle {
-		c.MemIOBuffer.WriteByte(byte(c.PC & 0x00FF))
-		c.MemIOBuffer.WriteByte(byte((c.PC & 0xFF00) >> 8))
+		c.MemIOBuffer.WriteByte(byte(c.accessPC & 0x00FF))
+		c.MemIOBuffer.WriteByte(byte((c.accessPC & 0xFF00) >> 8))
 		c.MemIOBuffer.WriteByte(0x01)
 		c.MemIOBuffer.WriteByte(byte(addr & 0x00FF))
 		c.MemIOBuffer.WriteByte(byte((addr & 0xFF00) >> 8))
 		c.MemIOBuffer.WriteByte(v)
+		c.MemIOBuffer.WriteByte(v)
 	}
 
 	return v
@@ -33,38 +20,130 @@ func (c *CPU) Fetch(addr uint16) byte {
 // Writes a byte to mapped memory
 func (c *CPU) Write(addr uint16, b byte) {
 	if c.EnableDebug && c.InCycle {
-		c.MemIOBuffer.WriteByte(byte(c.PC & 0x00FF))
-		c.MemIOBuffer.WriteByte(byte((c.PC & 0xFF00) >> 8))
+		c.MemIOBuffer.WriteByte(byte(c.accessPC & 0x00FF))
+		c.MemIOBuffer.WriteByte(byte((c.accessPC & 0xFF00) >> 8))
 		c.MemIOBuffer.WriteByte(0x02)
 		c.MemIOBuffer.WriteByte(byte(addr & 0x00FF))
 		c.MemIOBuffer.WriteByte(byte((addr & 0xFF00) >> 8))
+		c.MemIOBuffer.WriteByte(c.fetch(addr))
 		c.MemIOBuffer.WriteByte(b)
 	}
 
+	c.write(addr, b)
+}
+
+// write writes a byte to mapped memory without logging the access.
+func (c *CPU) write(addr uint16, b byte) {
 	switch AddrToMemType(addr) {
+	case ROM0, ROMX:
+		// TODO: MBC registers
+	case VRAM, OAM:
+		c.PPU.Write(addr, b)
+	case SRAM, WRAM0, WRAMX, HRAM:
+		c.Mem[addr] = b
+	case Echo:
+		c.Mem[addr-0x2000] = b
+	case Unused:
+		// Writes are ignored
 	case IO:
 		c.WriteIO(addr, b)
-	case VRAM:
-		c.PPU.Write(addr, b)
 	case IERegister:
 		c.WriteIE(b)
+	}
+}
+
+// Peek reads a byte from mapped memory without logging the access, it is
+// meant for debugging.
+func (c *CPU) Peek(addr uint16) byte {
+	return c.fetch(addr)
+}
+
+// Poke writes a byte to memory bypassing the hardware, it is meant for
+// debugging. ROM is patched, VRAM and OAM are written even while the PPU uses
+// them, and DIV, SB, and DMA are set without resetting the timer, sending
+// the byte, or copying OAM. Other I/O registers are written normally.
+func (c *CPU) Poke(addr uint16, b byte) {
+	switch AddrToMemType(addr) {
+	case ROM0, ROMX:
+		c.ROM[addr] = b
+	case VRAM:
+		c.PPU.WriteVRAM(addr, b)
+	case OAM:
+		c.PPU.WriteOAM(addr, b)
+	case IO:
+		switch addr {
+		case IODIV:
+			c.InternalDIV = uint16(b) << 8
+		case IOSB:
+			c.Serial.SB = b
+		case IODMA:
+			c.Mem[addr] = b
+		default:
+			c.WriteIO(addr, b)
+		}
 	default:
-		c.Mem[addr] = b // TODO remove default case
+		c.write(addr, b)
+	}
+}
+
+// fetch reads a byte from mapped memory without logging the access.
+func (c *CPU) fetch(addr uint16) byte {
+	switch AddrToMemType(addr) {
+	case ROM0:
+		return c.FetchROM0(addr)
+	case ROMX:
+		return c.FetchROMX(addr)
+	case VRAM, OAM:
+		return c.PPU.Fetch(addr)
+	case SRAM, WRAM0, WRAMX, HRAM:
+		return c.Mem[addr]
+	case Echo:
+		// Mirrors C000-DDFF
+		return c.Mem[addr-0x2000]
+	case Unused:
+		// Reads 0x00 on DMG, unless OAM is blocked by the PPU
+		if !c.PPU.OAMAccessible() {
+			return 0xFF
+		}
+		return 0x00
+	case IO:
+		return c.FetchIO(addr)
+	case IERegister:
+		return c.FetchIE()
 	}
+
+	panic("unreachable")
 }
 
 func (c *CPU) FetchROM0(addr uint16) byte {
-	// During bootstrap 0x0000-0x00FF is mapped to boot ROM
-	if c.FetchIO(IODisableBootROM) == 0 && addr <= 0xFF {
-		return c.Boot[addr]
+	// During bootstrap 0x0000-0x00FF is mapped to boot ROM, so is
+	// 0x0200-0x08FF on CGB
+	if c.FetchIO(IODisableBootROM) == 0 {
+		if addr <= 0xFF || (c.CGB && addr >= 0x200 && addr < 0x900) {
+			return c.Boot[addr]
+		}
 	}
 
-	return c.ROM[addr]
+	return c.Cheats.PatchROM(addr, c.ROM[addr])
 }
 
 // TODO: bank switch
 func (c *CPU) FetchROMX(addr uint16) byte {
-	return c.ROM[addr]
+	return c.Cheats.PatchROM(addr, c.ROM[addr])
+}
+
+// ROMBank returns the ROM bank mapped at the given address, 0 for ROM0 and
+// -1 outside of ROM.
+// TODO: bank switch
+func (c *CPU) ROMBank(addr uint16) int {
+	switch AddrToMemType(addr) {
+	case ROM0:
+		return 0
+	case ROMX:
+		return 1
+	}
+
+	return -1
 }
 
 type MemType int
@@ -0,0 +1,97 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/L-P/poussin/emu/ppu"
+)
+
+func TestEchoRAM(t *testing.T) {
+	cases := []struct {
+		write, read uint16
+	}{
+		{0xC000, 0xE000},
+		{0xDDFF, 0xFDFF},
+		{0xE123, 0xC123},
+		{0xFDFF, 0xDDFF},
+	}
+
+	c := newTestCPU(t)
+	for i, v := range cases {
+		value := byte(0x10 + i)
+		c.Write(v.write, value)
+		if actual := c.Fetch(v.read); actual != value {
+			t.Errorf("%04X: expected %02X written at %04X, got %02X", v.read, value, v.write, actual)
+		}
+	}
+}
+
+// seek puts the PPU in the given mode with the LCD on.
+func seek(c *CPU, mode byte) {
+	c.PPU.LCDC |= ppu.LCDCControl
+
+	switch mode {
+	case ppu.ModeHBlank:
+		c.PPU.Seek(0, 300)
+	case ppu.ModeVBlank:
+		c.PPU.Seek(144, 0)
+	case ppu.ModeOAM:
+		c.PPU.Seek(0, 0)
+	case ppu.ModeTransfer:
+		c.PPU.Seek(0, 100)
+	}
+}
+
+func TestUnusedRegion(t *testing.T) {
+	cases := []struct {
+		lcdOn    bool
+		mode     byte
+		expected byte
+	}{
+		{false, ppu.ModeHBlank, 0x00},
+		{true, ppu.ModeHBlank, 0x00},
+		{true, ppu.ModeVBlank, 0x00},
+		{true, ppu.ModeOAM, 0xFF},
+		{true, ppu.ModeTransfer, 0xFF},
+	}
+
+	for _, v := range cases {
+		c := newTestCPU(t)
+		if v.lcdOn {
+			seek(c, v.mode)
+		}
+
+		c.Write(0xFEA0, 0x12)
+		for _, addr := range []uint16{0xFEA0, 0xFEFF} {
+			if actual := c.Fetch(addr); actual != v.expected {
+				t.Errorf("LCD %t mode %d %04X: expected %02X, got %02X", v.lcdOn, v.mode, addr, v.expected, actual)
+			}
+		}
+	}
+}
+
+func TestBlockedWrites(t *testing.T) {
+	cases := []struct {
+		mode          byte
+		vramOK, oamOK bool
+	}{
+		{ppu.ModeHBlank, true, true},
+		{ppu.ModeVBlank, true, true},
+		{ppu.ModeOAM, true, false},
+		{ppu.ModeTransfer, false, false},
+	}
+
+	for _, v := range cases {
+		c := newTestCPU(t)
+		seek(c, v.mode)
+		c.Write(0x8000, 0x12)
+		c.Write(0xFE00, 0x34)
+
+		if written := c.PPU.FetchVRAM(0x8000) == 0x12; written != v.vramOK {
+			t.Errorf("mode %d: expected VRAM write %t, got %t", v.mode, v.vramOK, written)
+		}
+		if written := c.PPU.FetchOAM(0xFE00) == 0x34; written != v.oamOK {
+			t.Errorf("mode %d: expected OAM write %t, got %t", v.mode, v.oamOK, written)
+		}
+	}
+}
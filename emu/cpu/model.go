@@ -0,0 +1,137 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/L-P/poussin/emu/rom"
+	"github.com/L-P/poussin/emu/sgb"
+)
+
+// Model is the emulated Game Boy hardware revision.
+type Model int
+
+// Models, in release order.
+const (
+	ModelDMG0 = Model(iota) // Early DMG boot ROM
+	ModelDMG                // Game Boy
+	ModelMGB                // Game Boy Pocket
+	ModelSGB                // Super Game Boy
+	ModelSGB2               // Super Game Boy 2
+	ModelCGB                // Game Boy Color
+)
+
+var modelNames = [...]string{
+	ModelDMG0: "dmg0",
+	ModelDMG:  "dmg",
+	ModelMGB:  "mgb",
+	ModelSGB:  "sgb",
+	ModelSGB2: "sgb2",
+	ModelCGB:  "cgb",
+}
+
+func (m Model) String() string {
+	return modelNames[m]
+}
+
+// ParseModel returns the Model matching the given name.
+func ParseModel(name string) (Model, error) {
+	for i, v := range modelNames {
+		if strings.EqualFold(v, name) {
+			return Model(i), nil
+		}
+	}
+
+	return ModelDMG, fmt.Errorf(
+		"unknown model %q, expected one of: %s",
+		name,
+		strings.Join(modelNames[:], ", "),
+	)
+}
+
+// IsSGB returns true for the models running in a Super Game Boy.
+func (m Model) IsSGB() bool {
+	return m == ModelSGB || m == ModelSGB2
+}
+
+// postBootState is the state the boot ROM leaves the hardware in when it
+// jumps to 0x0100.
+type postBootState struct {
+	A, F       byte
+	BC, DE, HL uint16
+
+	// Internal DIV counter
+	DIV uint16
+
+	// Position of the PPU
+	Line, Dot int
+
+	NR52 byte
+}
+
+var postBootStates = [...]postBootState{
+	ModelDMG0: {A: 0x01, F: 0x00, BC: 0xFF13, DE: 0x00C1, HL: 0x8403, DIV: 0x182C, Line: 152, Dot: 400, NR52: 0xF1},
+	ModelDMG:  {A: 0x01, F: 0xB0, BC: 0x0013, DE: 0x00D8, HL: 0x014D, DIV: 0xABCC, Line: 153, Dot: 400, NR52: 0xF1},
+	ModelMGB:  {A: 0xFF, F: 0xB0, BC: 0x0013, DE: 0x00D8, HL: 0x014D, DIV: 0xABCC, Line: 153, Dot: 400, NR52: 0xF1},
+	ModelSGB:  {A: 0x01, F: 0x00, BC: 0x0014, DE: 0x0000, HL: 0xC060, DIV: 0xD85C, Line: 153, Dot: 400, NR52: 0xF0},
+	ModelSGB2: {A: 0xFF, F: 0x00, BC: 0x0014, DE: 0x0000, HL: 0xC060, DIV: 0xD85C, Line: 153, Dot: 400, NR52: 0xF0},
+	ModelCGB:  {A: 0x11, F: 0x80, BC: 0x0000, DE: 0xFF56, HL: 0x000D, DIV: 0x1EA0, Line: 144, Dot: 40, NR52: 0xF1},
+}
+
+// postBootSound are the sound registers values after boot, they are the
+// same on all models.
+var postBootSound = map[uint16]byte{
+	IONR10: 0x80, IONR11: 0xBF, IONR12: 0xF3, IONR13: 0xFF, IONR14: 0xBF,
+	IONR21: 0x3F, IONR22: 0x00, IONR23: 0xFF, IONR24: 0xBF,
+	IONR30: 0x7F, IONR31: 0xFF, IONR32: 0x9F, IONR33: 0xFF, IONR34: 0xBF,
+	IONR41: 0xFF, IONR42: 0x00, IONR43: 0x00, IONR44: 0xBF,
+	IONR50: 0x77, IONR51: 0xF3,
+}
+
+// SetModel selects the emulated hardware, it must be called before loading
+// any ROM.
+func (c *CPU) SetModel(m Model) {
+	c.Model = m
+	c.CGB = m == ModelCGB
+	c.setCGBMode(c.CGB)
+
+	c.SGB = nil
+	if m.IsSGB() {
+		c.SGB = sgb.New()
+	}
+}
+
+// applyPostBootState sets the registers as the boot ROM of the current model
+// would leave them.
+func (c *CPU) applyPostBootState() {
+	s := postBootStates[c.Model]
+
+	c.A = s.A
+	c.SetF(s.F)
+	c.BC = s.BC
+	c.DE = s.DE
+	c.HL = s.HL
+	c.PC = 0x0100
+	c.SP = 0xFFFE
+
+	// The boot ROM compares the header checksum, leaving H and C set
+	// unless it is 0.
+	if (c.Model == ModelDMG || c.Model == ModelMGB) && c.ROM[rom.HeaderChecksum] == 0x00 {
+		c.SetF(0x80)
+	}
+
+	c.InternalDIV = s.DIV
+	c.Mem[IOP1] = 0xCF
+	for addr, v := range postBootSound {
+		c.Mem[addr] = v
+	}
+	c.Mem[IONR52] = s.NR52
+
+	c.PPU.LCDC = 0x91
+	c.PPU.BGP = 0xFC
+	c.PPU.DMA = 0xFF
+	if c.CGB {
+		c.PPU.DMA = 0x00
+	}
+	c.PPU.Seek(s.Line, s.Dot)
+}
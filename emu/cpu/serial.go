@@ -0,0 +1,129 @@
+package cpu
+
+const (
+	// SCTransferStart is set to start a transfer, it is reset by the
+	// hardware when the transfer completes.
+	SCTransferStart = 1 << 7
+
+	// SCInternalClock selects the internal 8192 Hz clock, when unset the
+	// clock is driven by the peer.
+	SCInternalClock = 1 << 0
+
+	// serialBitCycles is the number of cycles needed to shift one bit using
+	// the internal clock (4194304 Hz / 8192 Hz).
+	serialBitCycles = 512
+)
+
+// SerialPeer is a device plugged at the other end of the link cable.
+type SerialPeer interface {
+	// Transfer is called when a transfer clocked by this Game Boy completes,
+	// it is given the byte we shifted out and returns the byte the peer
+	// shifted in.
+	Transfer(out byte) byte
+
+	// Sync is called after every CPU step with the number of cycles
+	// elapsed. Peers that drive the clock themselves call
+	// Serial.ExternalTransfer from there.
+	Sync(s *Serial, cycles int)
+}
+
+// Serial is the serial I/O controller, it shifts SB out and in through the
+// link port when a transfer is requested in SC.
+type Serial struct {
+	// Peer is what is plugged in the link port, nil when nothing is.
+	Peer SerialPeer
+
+	// SB is the serial transfer data.
+	SB byte
+
+	// SC is the serial transfer control, unused bits are not stored.
+	SC byte
+
+	// Cycles left before the current internal clock transfer completes.
+	transferCycles int
+
+	// Set when a transfer completed and the interrupt was not yet requested.
+	completed bool
+}
+
+// WriteSC writes the serial control register and starts a transfer if asked.
+func (s *Serial) WriteSC(value byte) {
+	s.SC = value & (SCTransferStart | SCInternalClock)
+	s.transferCycles = 0
+
+	if s.SC == SCTransferStart|SCInternalClock {
+		s.transferCycles = 8 * serialBitCycles
+	}
+}
+
+// FetchSC returns the serial control register value.
+func (s *Serial) FetchSC() byte {
+	// Bits 1-6 are unused and always set
+	return s.SC | 0x7E
+}
+
+// Transferring returns true if a transfer was requested and is not complete.
+func (s *Serial) Transferring() bool {
+	return s.SC&SCTransferStart == SCTransferStart
+}
+
+// ExternalTransfer is called by a peer driving the clock, it exchanges the
+// given byte with SB if we were waiting for an external clock transfer.
+// When we are not, the peer reads 0xFF as if nothing was connected.
+func (s *Serial) ExternalTransfer(in byte) byte {
+	if !s.Transferring() || s.SC&SCInternalClock == SCInternalClock {
+		return 0xFF
+	}
+
+	out := s.SB
+	s.complete(in)
+
+	return out
+}
+
+// Update runs the serial controller for the given number of cycles and
+// returns true if a transfer completed and the serial interrupt should be
+// requested.
+func (s *Serial) Update(cycles int) bool {
+	if s.transferCycles > 0 {
+		s.transferCycles -= cycles
+		if s.transferCycles <= 0 {
+			s.transferCycles = 0
+
+			// With nothing connected the input line is pulled up.
+			in := byte(0xFF)
+			if s.Peer != nil {
+				in = s.Peer.Transfer(s.SB)
+			}
+			s.complete(in)
+		}
+	}
+
+	if s.Peer != nil {
+		s.Peer.Sync(s, cycles)
+	}
+
+	completed := s.completed
+	s.completed = false
+
+	return completed
+}
+
+func (s *Serial) complete(in byte) {
+	s.SB = in
+	s.SC &^= SCTransferStart
+	s.completed = true
+}
+
+// UpdateSerial runs the serial controller based on cycle count.
+func (c *CPU) UpdateSerial() {
+	delta := c.Cycle - c.LastSerialUpdateCycle
+	if delta <= 0 {
+		return
+	}
+	c.LastSerialUpdateCycle = c.Cycle
+
+	if c.Serial.Update(delta) {
+		c.SetIF(IESerial)
+	}
+}
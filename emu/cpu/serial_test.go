@@ -0,0 +1,103 @@
+package cpu
+
+import (
+	"testing"
+)
+
+// testPeer answers internal clock transfers with in and starts an external
+// clock transfer when clock is set.
+type testPeer struct {
+	in, out   byte
+	transfers int
+	clock     bool
+}
+
+func (p *testPeer) Transfer(out byte) byte {
+	p.out = out
+	p.transfers++
+
+	return p.in
+}
+
+func (p *testPeer) Sync(s *Serial, cycles int) {
+	if p.clock {
+		p.clock = false
+		p.out = s.ExternalTransfer(p.in)
+		p.transfers++
+	}
+}
+
+func TestSerialInternalClock(t *testing.T) {
+	cases := []struct {
+		doubleSpeed bool
+		peer        *testPeer
+		expectedSB  byte
+
+		// expectedCycles is the duration in normal speed cycles, the CPU
+		// clock and the serial clock both double in double speed.
+		expectedCycles int
+	}{
+		{false, nil, 0xFF, 8 * serialBitCycles},
+		{false, &testPeer{in: 0x42}, 0x42, 8 * serialBitCycles},
+		{true, &testPeer{in: 0x42}, 0x42, 4 * serialBitCycles},
+	}
+
+	for i, v := range cases {
+		c := newTestCPU(t)
+		c.DoubleSpeed = v.doubleSpeed
+		if v.peer != nil {
+			c.Serial.Peer = v.peer
+		}
+
+		c.Write(IOSB, 0x81)
+		c.Write(IOSC, SCTransferStart|SCInternalClock)
+		start := c.Cycle
+		for c.Serial.Transferring() {
+			step(t, c)
+			if c.Cycle-start > 16*serialBitCycles {
+				t.Fatalf("%d: transfer did not complete", i)
+			}
+		}
+
+		elapsed := c.Cycle - start
+		if v.doubleSpeed {
+			elapsed /= 2
+		}
+		if elapsed != v.expectedCycles {
+			t.Errorf("%d: expected the transfer to take %d cycles, took %d", i, v.expectedCycles, elapsed)
+		}
+		if actual := c.Fetch(IOSB); actual != v.expectedSB {
+			t.Errorf("%d: expected SB=%02X, got %02X", i, v.expectedSB, actual)
+		}
+		if !c.IFIsSet(IESerial) {
+			t.Errorf("%d: expected the serial interrupt to be requested", i)
+		}
+		if v.peer != nil && (v.peer.transfers != 1 || v.peer.out != 0x81) {
+			t.Errorf("%d: expected the peer to receive 81 once, got %02X %d times", i, v.peer.out, v.peer.transfers)
+		}
+	}
+}
+
+func TestSerialExternalClock(t *testing.T) {
+	peer := &testPeer{in: 0x42}
+	c := newTestCPU(t)
+	c.Serial.Peer = peer
+
+	c.Write(IOSB, 0x81)
+	c.Write(IOSC, SCTransferStart)
+	for i := 0; i < 4*serialBitCycles; i++ {
+		step(t, c)
+	}
+	if !c.Serial.Transferring() || c.IFIsSet(IESerial) || peer.transfers != 0 {
+		t.Fatal("expected the transfer to wait for the peer clock")
+	}
+
+	peer.clock = true
+	step(t, c)
+	if c.Serial.Transferring() || !c.IFIsSet(IESerial) {
+		t.Error("expected the transfer to complete when the peer clocked it")
+	}
+	if actual := c.Fetch(IOSB); actual != 0x42 || peer.out != 0x81 {
+		t.Errorf("expected to exchange 81 for 42, got SB=%02X and the peer got %02X", actual, peer.out)
+	}
+}
@@ -0,0 +1,327 @@
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jroimartin/gocui"
+)
+
+// anyBank matches all banks.
+const anyBank = -1
+
+// breakpoint pauses the execution when PC reaches Addr in Bank.
+type breakpoint struct {
+	Bank    int
+	Addr    uint16
+	Enabled bool
+	Hits    int
+
+	// Condition must be true for the breakpoint to trigger, empty for
+	// always.
+	Condition string
+	cond      expr
+
+	// Trace makes a tracepoint that prints a message instead of pausing.
+	Trace string
+	trace traceFormat
+}
+
+func (b breakpoint) location() string {
+	return formatAddress(b.Bank, b.Addr)
+}
+
+func (b breakpoint) String() string {
+	state := "on"
+	if !b.Enabled {
+		state = "off"
+	}
+
+	return fmt.Sprintf("%s %-3s %d%s", b.location(), state, b.Hits, b.options())
+}
+
+// options returns the condition and trace message as they are typed.
+func (b breakpoint) options() string {
+	var str string
+	if b.Condition != "" {
+		str += " if " + b.Condition
+	}
+	if b.Trace != "" {
+		str += " trace " + b.Trace
+	}
+
+	return str
+}
+
+// parseOptions sets the condition and trace message from a string like
+// "if A == 3 trace A is {A}".
+func (b *breakpoint) parseOptions(str string) error {
+	rest := strings.TrimSpace(str)
+
+	if strings.HasPrefix(rest, "if ") {
+		b.Condition, rest = rest[3:], ""
+		if i := strings.Index(b.Condition, " trace "); i >= 0 {
+			b.Condition, rest = b.Condition[:i], b.Condition[i+1:]
+		}
+
+		var err error
+		b.Condition = strings.TrimSpace(b.Condition)
+		if b.cond, err = parseExpr(b.Condition); err != nil {
+			return err
+		}
+	}
+
+	if strings.HasPrefix(rest, "trace ") {
+		b.Trace, rest = strings.TrimSpace(rest[6:]), ""
+
+		var err error
+		if b.trace, err = parseTraceFormat(b.Trace); err != nil {
+			return err
+		}
+	}
+
+	if rest != "" {
+		return fmt.Errorf("unexpected %q, expected if CONDITION or trace MESSAGE", rest)
+	}
+
+	return nil
+}
+
+// matches returns true if the breakpoint triggers at addr.
+func (b breakpoint) matches(bank int, addr uint16) bool {
+	return b.Enabled && b.Addr == addr && (b.Bank == anyBank || bank == anyBank || b.Bank == bank)
+}
+
+// formatAddress returns an address as BB:AAAA, or AAAA if the bank is not
+// known.
+func formatAddress(bank int, addr uint16) string {
+	if bank == anyBank {
+		return fmt.Sprintf("%04X", addr)
+	}
+
+	return fmt.Sprintf("%02X:%04X", bank, addr)
+}
+
+// parseHexAddress parses an hex address optionally prefixed by its bank, eg.
+// 01:4000.
+func parseHexAddress(str string) (int, uint16, error) {
+	bank := anyBank
+	if i := strings.Index(str, ":"); i >= 0 {
+		v, err := strconv.ParseUint(str[:i], 16, 8)
+		if err != nil {
+			return bank, 0, fmt.Errorf("invalid bank %q", str[:i])
+		}
+		bank = int(v)
+		str = str[i+1:]
+	}
+
+	addr, err := strconv.ParseUint(str, 16, 16)
+	if err != nil {
+		return bank, 0, fmt.Errorf("invalid address %q", str)
+	}
+
+	return bank, uint16(addr), nil
+}
+
+// checkBreakpoints pauses the execution if the current PC has a breakpoint.
+func (d *Debugger) checkBreakpoints() {
+	// PC does not move while halted, no instruction ran since the last check.
+	if len(d.breakpoints) == 0 || (d.cpu.Halted && !d.cpu.LastCycleWasInterrupt) {
+		return
+	}
+
+	bank := d.cpu.ROMBank(d.cpu.PC)
+	for i := range d.breakpoints {
+		b := &d.breakpoints[i]
+		if !b.matches(bank, d.cpu.PC) || (b.cond != nil && b.cond(d) == 0) {
+			continue
+		}
+
+		b.Hits++
+		if b.Trace != "" {
+			d.msgBuffer.WriteString(b.trace.format(d) + "\n")
+			continue
+		}
+
+		atomic.StoreInt32(&d.flowState, FlowPause)
+		d.msgBuffer.WriteString(fmt.Sprintf("breakpoint #%X hit at %s\n", i, formatAddress(bank, d.cpu.PC)))
+	}
+}
+
+func (d *Debugger) cbBreakpoint(g *gocui.Gui, v *gocui.View) error {
+	if d.hasModal.IsSet() {
+		return nil
+	}
+
+	return d.inputModal(g, "Breakpoint", d.breakpointCommand)
+}
+
+// breakpointCommand runs a breakpoint command:
+//
+//	[BB:]AAAA [if COND] [trace MSG]  add a breakpoint, only triggered if
+//	                                 COND is true, MSG makes a tracepoint
+//	                                 logging {expr} values without pausing
+//	toggle I                         enable or disable the Ith breakpoint
+//	delete I                         remove the Ith breakpoint
+func (d *Debugger) breakpointCommand(cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return errors.New("expected a breakpoint command")
+	}
+	if fields[0] != "toggle" && fields[0] != "delete" {
+		b, err := d.parseBreakpoint(cmd)
+		if err != nil {
+			return err
+		}
+
+		return d.addBreakpoint(b)
+	}
+
+	if len(fields) != 2 {
+		return fmt.Errorf("unknown breakpoint command %q", cmd)
+	}
+
+	i, err := strconv.ParseUint(fields[1], 16, 8)
+	if err != nil {
+		return err
+	}
+	if int(i) >= len(d.breakpoints) {
+		return fmt.Errorf("no breakpoint #%X", i)
+	}
+
+	switch fields[0] {
+	case "toggle":
+		d.breakpoints[i].Enabled = !d.breakpoints[i].Enabled
+	case "delete":
+		d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+	default:
+		return fmt.Errorf("unknown breakpoint command %q", cmd)
+	}
+
+	return d.saveBreakpoints()
+}
+
+// parseBreakpoint parses a breakpoint location followed by its options.
+func (d *Debugger) parseBreakpoint(str string) (breakpoint, error) {
+	fields := strings.SplitN(strings.TrimSpace(str), " ", 2)
+	bank, addr, err := d.parseAddress(fields[0])
+	if err != nil {
+		return breakpoint{}, err
+	}
+
+	// Banks only make sense in ROM.
+	if addr >= 0x8000 {
+		bank = anyBank
+	}
+
+	b := breakpoint{Bank: bank, Addr: addr, Enabled: true}
+	if len(fields) > 1 {
+		if err := b.parseOptions(fields[1]); err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+func (d *Debugger) addBreakpoint(b breakpoint) error {
+	for _, v := range d.breakpoints {
+		if v.Bank == b.Bank && v.Addr == b.Addr && v.options() == b.options() {
+			return fmt.Errorf("breakpoint already set at %s", b.location())
+		}
+	}
+
+	d.breakpoints = append(d.breakpoints, b)
+
+	return d.saveBreakpoints()
+}
+
+// LoadBreakpoints reads the breakpoints from a file, changes are saved to it.
+// A missing file is not an error.
+func (d *Debugger) LoadBreakpoints(path string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.breakpointsPath = path
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		// location state [options]
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 3)
+		if fields[0] == "" {
+			continue
+		}
+
+		b, err := d.parseBreakpoint(fields[0])
+		if len(fields) > 2 && err == nil {
+			err = b.parseOptions(fields[2])
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+
+		b.Enabled = len(fields) < 2 || fields[1] != "disabled"
+		d.breakpoints = append(d.breakpoints, b)
+	}
+
+	return scanner.Err()
+}
+
+// saveBreakpoints writes the breakpoints to the file they were loaded from.
+func (d *Debugger) saveBreakpoints() error {
+	if d.breakpointsPath == "" {
+		return nil
+	}
+
+	if len(d.breakpoints) == 0 {
+		if err := os.Remove(d.breakpointsPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	f, err := os.Create(d.breakpointsPath)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range d.breakpoints {
+		state := "enabled"
+		if !b.Enabled {
+			state = "disabled"
+		}
+		fmt.Fprintf(f, "%s %s%s\n", b.location(), state, b.options())
+	}
+
+	return f.Close()
+}
+
+func (d *Debugger) updateBreakpointsWindow(g *gocui.Gui) error {
+	v, err := g.View("breakpoints")
+	if err != nil {
+		if err == gocui.ErrUnknownView {
+			return nil
+		}
+		return err
+	}
+	v.Clear()
+
+	for i, b := range d.breakpoints {
+		fmt.Fprintf(v, "%02X %s\n", i, b)
+	}
+
+	return nil
+}
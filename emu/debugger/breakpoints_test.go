@@ -0,0 +1,90 @@
+package debugger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+func TestBreakpointsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poussin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "game.breakpoints")
+
+	var d Debugger
+	if err := d.LoadBreakpoints(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"01:4000", "C000 if A == 3 trace A={A}", "toggle 1"} {
+		if err := d.breakpointCommand(cmd); err != nil {
+			t.Fatalf("%s: %s", cmd, err)
+		}
+	}
+
+	var loaded Debugger
+	if err := loaded.LoadBreakpoints(path); err != nil {
+		t.Fatal(err)
+	}
+	expected := []breakpoint{
+		{Bank: 1, Addr: 0x4000, Enabled: true},
+		{Bank: anyBank, Addr: 0xC000, Enabled: false, Condition: "A == 3", Trace: "A={A}"},
+	}
+	if len(loaded.breakpoints) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, loaded.breakpoints)
+	}
+	for i, v := range expected {
+		b := loaded.breakpoints[i]
+		if b.String() != v.String() {
+			t.Errorf("expected %v, got %v", v, b)
+		}
+	}
+
+	if b := loaded.breakpoints[0]; b.matches(2, 0x4000) || !b.matches(1, 0x4000) {
+		t.Error("expected the bank to be checked")
+	}
+}
+
+func TestBreakpointAfterHALT(t *testing.T) {
+	d := newTestDebugger(t)
+	c := d.cpu
+	c.Mem[cpu.IODisableBootROM] = 1
+	c.ROM[0x40] = 0xD9  // RETI
+	c.ROM[0x150] = 0x76 // HALT
+	c.PC = 0x150
+	c.SP = 0xFFFE
+	if err := d.breakpointCommand("0151"); err != nil {
+		t.Fatal(err)
+	}
+
+	step := func() {
+		if _, err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+		d.checkBreakpoints()
+	}
+
+	for i := 0; i < 10; i++ {
+		step()
+	}
+	if !c.Halted || d.breakpoints[0].Hits != 0 {
+		t.Fatalf("expected no hits while halted, got %d", d.breakpoints[0].Hits)
+	}
+
+	c.WriteIE(cpu.IEVBlank)
+	c.SetIF(cpu.IEVBlank)
+	step() // interrupt
+	step() // RETI
+	if c.PC != 0x151 || d.breakpoints[0].Hits != 1 {
+		t.Errorf("expected one hit once back from the interrupt at 0151, got %d at %04X", d.breakpoints[0].Hits, c.PC)
+	}
+
+	if err := d.breakpointCommand("\t"); err == nil {
+		t.Error("expected a blank command to be rejected")
+	}
+}
@@ -0,0 +1,179 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jroimartin/gocui"
+)
+
+// callStackMaxDepth is the number of frames kept, games resetting SP without
+// returning could otherwise grow the stack forever.
+const callStackMaxDepth = 256
+
+// callStackMaxMismatches is the number of mismatches listed in the view.
+const callStackMaxMismatches = 8
+
+// frame is an entry of the shadow call stack.
+type frame struct {
+	// Caller is the address of the CALL or RST, or the interrupted address.
+	Caller     uint16
+	CallerBank int
+
+	Target     uint16
+	TargetBank int
+
+	// SP points to the return address pushed by the call.
+	SP     uint16
+	Return uint16
+
+	Interrupt bool
+}
+
+func (f frame) String() string {
+	kind := 'C'
+	if f.Interrupt {
+		kind = 'I'
+	}
+
+	return fmt.Sprintf("%c %04X %s>%s", kind, f.SP, formatAddress(f.CallerBank, f.Caller), formatAddress(f.TargetBank, f.Target))
+}
+
+// pushFrame records the call or interrupt that just happened.
+func (d *Debugger) pushFrame(interrupt bool) {
+	sp := d.cpu.SP
+	f := frame{
+		Caller:     d.cpu.LastPC,
+		Target:     d.cpu.PC,
+		TargetBank: d.cpu.ROMBank(d.cpu.PC),
+		SP:         sp,
+		Return:     uint16(d.cpu.Peek(sp)) | uint16(d.cpu.Peek(sp+1))<<8,
+		Interrupt:  interrupt,
+	}
+	if interrupt {
+		f.Caller = f.Return
+	}
+	f.CallerBank = d.cpu.ROMBank(f.Caller)
+
+	if len(d.callStack) >= callStackMaxDepth {
+		d.callStack = d.callStack[1:]
+	}
+	d.callStack = append(d.callStack, f)
+}
+
+// popFrame matches the RET that just happened with the top of the stack.
+func (d *Debugger) popFrame() {
+	slot := d.cpu.SP - 2
+	d.unwindFrames(slot)
+
+	if len(d.callStack) == 0 || d.callStack[len(d.callStack)-1].SP != slot {
+		d.stackMismatch("%04X: RET %04X, no call", d.cpu.LastPC, d.cpu.PC)
+		return
+	}
+
+	top := d.callStack[len(d.callStack)-1]
+	if top.Return != d.cpu.PC {
+		d.stackMismatch("%04X: RET %04X!=%04X", d.cpu.LastPC, d.cpu.PC, top.Return)
+	}
+	d.callStack = d.callStack[:len(d.callStack)-1]
+}
+
+// unwindFrames drops the frames whose return address is below sp, they were
+// left without a RET by code changing SP directly.
+func (d *Debugger) unwindFrames(sp uint16) {
+	for len(d.callStack) > 0 {
+		top := d.callStack[len(d.callStack)-1]
+		if top.SP >= sp {
+			return
+		}
+
+		d.stackMismatch("%04X: dropped %04X", d.cpu.LastPC, top.Target)
+		d.callStack = d.callStack[:len(d.callStack)-1]
+	}
+}
+
+func (d *Debugger) stackMismatch(format string, args ...interface{}) {
+	d.callStackMismatchCount++
+	d.callStackMismatches = append(d.callStackMismatches, fmt.Sprintf(format, args...))
+	if len(d.callStackMismatches) > callStackMaxMismatches {
+		d.callStackMismatches = d.callStackMismatches[1:]
+	}
+}
+
+// stepOut runs until the current function returns. Without a caller the
+// depth to reach would never be, so it is refused.
+func (d *Debugger) stepOut() error {
+	if len(d.callStack) == 0 {
+		return errors.New("no caller to return to, the call stack is empty")
+	}
+
+	d.requestedDepth = d.callDepth - 1
+	atomic.StoreInt32(&d.flowState, FlowStepOut)
+
+	return nil
+}
+
+// consoleBacktrace prints the call stack, innermost frame first.
+func (d *Debugger) consoleBacktrace(string) error {
+	if len(d.callStack) == 0 {
+		d.msgBuffer.WriteString("empty call stack\n")
+	}
+
+	for i := len(d.callStack) - 1; i >= 0; i-- {
+		f := d.callStack[i]
+		kind := "call"
+		if f.Interrupt {
+			kind = "interrupt"
+		}
+
+		d.msgBuffer.WriteString(fmt.Sprintf(
+			"#%d %s %s from %s, SP=%04X\n",
+			len(d.callStack)-1-i,
+			kind,
+			d.describeAddress(f.TargetBank, f.Target),
+			d.describeAddress(f.CallerBank, f.Caller),
+			f.SP,
+		))
+	}
+
+	return nil
+}
+
+// describeAddress returns an address followed by its label if it has one.
+func (d *Debugger) describeAddress(bank int, addr uint16) string {
+	str := formatAddress(bank, addr)
+	if label := d.symbols.describe(bank, addr); label != "" {
+		str += " " + label
+	}
+
+	return str
+}
+
+func (d *Debugger) updateCallStackWindow(g *gocui.Gui) error {
+	v, err := g.View("call stack")
+	if err != nil {
+		if err == gocui.ErrUnknownView {
+			return nil
+		}
+		return err
+	}
+	v.Clear()
+
+	for i := len(d.callStack) - 1; i >= 0; i-- {
+		f := d.callStack[i]
+		fmt.Fprintln(v, f)
+		if label, ok := d.symbols.lookup(f.TargetBank, f.Target); ok {
+			fmt.Fprintf(v, "  \x1b[1;33m%s\x1b[0m\n", label)
+		}
+	}
+
+	if d.callStackMismatchCount > 0 {
+		fmt.Fprintf(v, "\x1b[1;31m%d mismatches\x1b[0m\n", d.callStackMismatchCount)
+		for _, m := range d.callStackMismatches {
+			fmt.Fprintln(v, m)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package debugger
+
+import (
+	"testing"
+)
+
+func TestCallStack(t *testing.T) {
+	d := newTestDebugger(t)
+	c := d.cpu
+
+	// step sets the CPU state after an instruction and updates the stack.
+	step := func(opcode byte, lastPC, pc, sp uint16, interrupt bool) {
+		c.LastOpcode = opcode
+		c.LastPC = lastPC
+		c.PC = pc
+		c.SP = sp
+		c.LastCycleWasInterrupt = interrupt
+		d.updateCallDepth()
+	}
+
+	c.Mem[0xDFFC], c.Mem[0xDFFD] = 0x53, 0x01
+	step(0xCD, 0x0150, 0x4000, 0xDFFC, false) // CALL $4000
+
+	c.Mem[0xDFFA], c.Mem[0xDFFB] = 0x10, 0x40
+	step(0x00, 0x400F, 0x0040, 0xDFFA, true) // VBlank
+
+	expected := []frame{
+		{Caller: 0x0150, CallerBank: 0, Target: 0x4000, TargetBank: 1, SP: 0xDFFC, Return: 0x0153},
+		{Caller: 0x4010, CallerBank: 1, Target: 0x0040, TargetBank: 0, SP: 0xDFFA, Return: 0x4010, Interrupt: true},
+	}
+	if len(d.callStack) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, d.callStack)
+	}
+	for i, v := range expected {
+		if d.callStack[i] != v {
+			t.Errorf("frame %d: expected %s, got %s", i, v, d.callStack[i])
+		}
+	}
+
+	step(0xD9, 0x0045, 0x4010, 0xDFFC, false) // RETI
+	if d.callDepth != 1 || d.callStackMismatchCount != 0 {
+		t.Errorf("expected RETI to pop the interrupt, got %v", d.callStack)
+	}
+
+	step(0xE1, 0x4010, 0x4011, 0xDFFE, false) // POP HL
+	if d.callDepth != 0 || d.callStackMismatchCount != 1 {
+		t.Errorf("expected POP to drop the call with a mismatch, got %v and %d mismatches", d.callStack, d.callStackMismatchCount)
+	}
+
+	step(0xC9, 0x4011, 0x0200, 0xE000, false) // RET
+	if d.callDepth != 0 || d.callStackMismatchCount != 2 {
+		t.Errorf("expected RET without CALL to be a mismatch, got %d mismatches", d.callStackMismatchCount)
+	}
+	if len(d.callStackMismatches) != 2 || d.callStackMismatches[1] != "4011: RET 0200, no call" {
+		t.Errorf("unexpected mismatches %q", d.callStackMismatches)
+	}
+}
+
+func TestStepOut(t *testing.T) {
+	d := newTestDebugger(t)
+	c := d.cpu
+	d.flowState = FlowPause
+
+	if err := d.runConsoleCommand("finish"); err == nil {
+		t.Error("expected finish to be refused with an empty call stack")
+	}
+	if d.flowState != FlowPause {
+		t.Errorf("expected to stay paused, got flow state %d", d.flowState)
+	}
+
+	c.Mem[0xDFFC], c.Mem[0xDFFD] = 0x53, 0x01
+	c.LastOpcode, c.LastPC, c.PC, c.SP = 0xCD, 0x0150, 0x4000, 0xDFFC // CALL $4000
+	d.updateCallDepth()
+	if err := d.runConsoleCommand("finish"); err != nil {
+		t.Fatal(err)
+	}
+
+	c.LastOpcode, c.LastPC, c.PC, c.SP = 0xC9, 0x4010, 0x0153, 0xDFFE // RET
+	d.updateCallDepth()
+	if d.flowState != FlowStepOut || d.callDepth != d.requestedDepth {
+		t.Errorf("expected to reach the requested depth %d once returned, got %d", d.requestedDepth, d.callDepth)
+	}
+}
@@ -0,0 +1,38 @@
+package debugger
+
+import (
+	"fmt"
+
+	"github.com/jroimartin/gocui"
+)
+
+func (d *Debugger) cbToggleCheat(g *gocui.Gui, v *gocui.View) error {
+	if d.hasModal.IsSet() {
+		return nil
+	}
+
+	cheats := d.cpu.Cheats
+	if len(cheats) == 0 {
+		d.msgBuffer.WriteString("no cheats loaded\n")
+		return nil
+	}
+
+	d.printCheats()
+	cb := func(i byte) {
+		if int(i) >= len(cheats) {
+			d.msgBuffer.WriteString(fmt.Sprintf("no cheat #%X\n", i))
+			return
+		}
+
+		cheats[i].SetEnabled(!cheats[i].Enabled())
+		d.msgBuffer.WriteString(fmt.Sprintf("%02X %s\n", i, cheats[i]))
+	}
+
+	return d.inputUInt8Modal(g, "Toggle cheat #", cb)
+}
+
+func (d *Debugger) printCheats() {
+	for i, c := range d.cpu.Cheats {
+		d.msgBuffer.WriteString(fmt.Sprintf("%02X %s\n", i, c))
+	}
+}
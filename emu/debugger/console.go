@@ -0,0 +1,403 @@
+package debugger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/L-P/poussin/emu/cpu"
+	"github.com/jroimartin/gocui"
+)
+
+// consoleCommand is a command typed in the console.
+type consoleCommand struct {
+	name  string
+	usage string
+	run   func(d *Debugger, args string) error
+}
+
+var consoleCommands []consoleCommand
+
+func init() {
+	consoleCommands = []consoleCommand{
+		{"backtrace", "backtrace  list the calls and interrupts being run", (*Debugger).consoleBacktrace},
+		{"break", "break [[BB:]AAAA [if COND] [trace MSG] | toggle I | delete I]", (*Debugger).consoleBreak},
+		{"continue", "continue", (*Debugger).consoleContinue},
+		{"disasm", "disasm [AAAA [N]]  disassemble N instructions from AAAA or PC", (*Debugger).consoleDisasm},
+		{"finish", "finish  run until the current function returns", (*Debugger).consoleFinish},
+		{"help", "help", (*Debugger).consoleHelp},
+		{"regs", "regs", (*Debugger).consoleRegs},
+		{"poke", "poke AAAA VV [VV...]  write raw bytes, bypassing I/O side effects", (*Debugger).pokeCommand},
+		{"set", "set REG|FLAG|IOREG|[ADDR]=EXPR  change a register, flag, or memory byte", (*Debugger).setCommand},
+		{"step", "step [N]  run N instructions", (*Debugger).consoleStep},
+		{"until", "until [BB:]AAAA  run until PC reaches AAAA", (*Debugger).consoleUntil},
+		{"watch", "watch [r|w|rw AAAA[-AAAA] [= VV] | toggle I | delete I]", (*Debugger).consoleWatch},
+		{"x", "x[/N] AAAA  dump N bytes of memory", (*Debugger).consoleExamine},
+	}
+}
+
+// consoleAliases are the short names of the commands used the most, they
+// take precedence over abbreviations.
+var consoleAliases = map[string]string{
+	"b":  "break",
+	"bt": "backtrace",
+	"c":  "continue",
+	"s":  "step",
+}
+
+// consoleHistorySize is the number of commands kept in the history.
+const consoleHistorySize = 100
+
+// consoleMaxCandidates is the number of completions listed at most.
+const consoleMaxCandidates = 64
+
+// console holds the state of the command line.
+type console struct {
+	focused bool
+	history []string
+
+	// historyPos is the position in history while browsing it, equal to
+	// len(history) when typing a new command.
+	historyPos int
+}
+
+func (d *Debugger) cbFocusConsole(g *gocui.Gui, v *gocui.View) error {
+	if d.hasModal.IsSet() {
+		return nil
+	}
+
+	d.console.focused = true
+	return nil
+}
+
+func (d *Debugger) cbUnfocusConsole(g *gocui.Gui, v *gocui.View) error {
+	d.console.focused = false
+	return nil
+}
+
+func (d *Debugger) cbConsoleEnter(g *gocui.Gui, v *gocui.View) error {
+	line := strings.TrimSpace(v.Buffer())
+	setConsoleLine(v, "")
+	if line == "" {
+		return nil
+	}
+
+	c := &d.console
+	if len(c.history) == 0 || c.history[len(c.history)-1] != line {
+		c.history = append(c.history, line)
+		if len(c.history) > consoleHistorySize {
+			c.history = c.history[1:]
+		}
+	}
+	c.historyPos = len(c.history)
+
+	d.msgBuffer.WriteString("> " + line + "\n")
+	if err := d.runConsoleCommand(line); err != nil {
+		d.msgBuffer.WriteString(err.Error() + "\n")
+	}
+
+	return nil
+}
+
+func (d *Debugger) cbConsoleHistoryUp(g *gocui.Gui, v *gocui.View) error {
+	c := &d.console
+	if c.historyPos > 0 {
+		c.historyPos--
+		setConsoleLine(v, c.history[c.historyPos])
+	}
+
+	return nil
+}
+
+func (d *Debugger) cbConsoleHistoryDown(g *gocui.Gui, v *gocui.View) error {
+	c := &d.console
+	if c.historyPos >= len(c.history) {
+		return nil
+	}
+
+	c.historyPos++
+	if c.historyPos == len(c.history) {
+		setConsoleLine(v, "")
+	} else {
+		setConsoleLine(v, c.history[c.historyPos])
+	}
+
+	return nil
+}
+
+// cbConsoleComplete completes the command name or the label being typed, or
+// lists the candidates if there are more than one.
+func (d *Debugger) cbConsoleComplete(g *gocui.Gui, v *gocui.View) error {
+	line := strings.TrimLeft(v.Buffer(), " ")
+	line = strings.TrimRight(line, "\n")
+
+	var head, word string
+	var candidates []string
+	if i := strings.LastIndexAny(line, " [=-"); i >= 0 {
+		head, word = line[:i+1], line[i+1:]
+		candidates = d.symbols.complete(word)
+	} else {
+		word = line
+		for _, c := range consoleCommands {
+			if strings.HasPrefix(c.name, word) {
+				candidates = append(candidates, c.name)
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+	case 1:
+		setConsoleLine(v, head+candidates[0]+" ")
+	default:
+		if len(candidates) > consoleMaxCandidates {
+			d.msgBuffer.WriteString(fmt.Sprintf("%d candidates\n", len(candidates)))
+		} else {
+			d.msgBuffer.WriteString(strings.Join(candidates, " ") + "\n")
+		}
+		setConsoleLine(v, head+commonPrefix(candidates))
+	}
+
+	return nil
+}
+
+func commonPrefix(strs []string) string {
+	prefix := strs[0]
+	for _, v := range strs[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+func setConsoleLine(v *gocui.View, line string) {
+	v.Clear()
+	fmt.Fprint(v, line)
+	v.SetCursor(len(line), 0)
+	v.SetOrigin(0, 0)
+}
+
+// runConsoleCommand runs a command line, the name can be abbreviated if it
+// is not ambiguous.
+func (d *Debugger) runConsoleCommand(line string) error {
+	fields := strings.SplitN(line, " ", 2)
+	name, args := fields[0], ""
+	if len(fields) > 1 {
+		args = strings.TrimSpace(fields[1])
+	}
+
+	// x/N carries its argument in the name.
+	if strings.HasPrefix(name, "x/") {
+		name, args = "x", name[1:]+" "+args
+	}
+	if alias, ok := consoleAliases[name]; ok {
+		name = alias
+	}
+
+	var match *consoleCommand
+	for i, c := range consoleCommands {
+		if c.name == name {
+			match = &consoleCommands[i]
+			break
+		}
+		if strings.HasPrefix(c.name, name) {
+			if match != nil {
+				return fmt.Errorf("ambiguous command %q", name)
+			}
+			match = &consoleCommands[i]
+		}
+	}
+
+	if match == nil {
+		return fmt.Errorf("unknown command %q, type help for a list", name)
+	}
+
+	return match.run(d, args)
+}
+
+func (d *Debugger) consoleHelp(string) error {
+	for _, c := range consoleCommands {
+		d.msgBuffer.WriteString(c.usage + "\n")
+	}
+	aliases := make([]string, 0, len(consoleAliases))
+	for k, v := range consoleAliases {
+		aliases = append(aliases, k+" "+v)
+	}
+	sort.Strings(aliases)
+	d.msgBuffer.WriteString("Aliases: " + strings.Join(aliases, ", ") + "\n")
+	d.msgBuffer.WriteString("Addresses are labels or hex, counts and expressions are decimal unless prefixed by 0x or $.\n")
+	d.msgBuffer.WriteString("Expressions use the C operators and precedence, A & 0x0F == 0x0F is A & 1.\n")
+
+	return nil
+}
+
+func (d *Debugger) consoleBreak(args string) error {
+	if args == "" {
+		for i, b := range d.breakpoints {
+			d.msgBuffer.WriteString(fmt.Sprintf("%02X %s\n", i, b))
+		}
+		return nil
+	}
+
+	return d.breakpointCommand(args)
+}
+
+func (d *Debugger) consoleWatch(args string) error {
+	if args == "" {
+		for i, w := range d.watchpoints {
+			d.msgBuffer.WriteString(fmt.Sprintf("%02X %s\n", i, w))
+		}
+		return nil
+	}
+
+	return d.watchpointCommand(args)
+}
+
+func (d *Debugger) consoleContinue(string) error {
+	atomic.StoreInt32(&d.flowState, FlowRun)
+	return nil
+}
+
+func (d *Debugger) consoleStep(args string) error {
+	n := 1
+	if args != "" {
+		var err error
+		if n, err = strconv.Atoi(args); err != nil || n < 1 {
+			return fmt.Errorf("invalid step count %q", args)
+		}
+	}
+
+	d.stepsLeft = n
+	atomic.StoreInt32(&d.flowState, FlowStepN)
+
+	return nil
+}
+
+func (d *Debugger) consoleFinish(string) error {
+	return d.stepOut()
+}
+
+func (d *Debugger) consoleUntil(args string) error {
+	_, addr, err := d.parseAddress(args)
+	if err != nil {
+		return err
+	}
+
+	d.stepToPC = addr
+	atomic.StoreInt32(&d.flowState, FlowStepToPC)
+
+	return nil
+}
+
+func (d *Debugger) consoleRegs(string) error {
+	d.runOnCPU(func() {
+		d.msgBuffer.WriteString(d.cpu.Registers.String() + "\n")
+	})
+
+	return nil
+}
+
+// consoleExamine dumps memory, args is "[/N] ADDR".
+func (d *Debugger) consoleExamine(args string) error {
+	n := 16
+	fields := strings.Fields(args)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "/") {
+		var err error
+		if n, err = strconv.Atoi(fields[0][1:]); err != nil || n < 1 {
+			return fmt.Errorf("invalid byte count %q", fields[0][1:])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return errors.New("usage: x[/N] AAAA")
+	}
+
+	_, addr, err := d.parseAddress(fields[0])
+	if err != nil {
+		return err
+	}
+
+	d.runOnCPU(func() {
+		var buf bytes.Buffer
+		for i := 0; i < n; i++ {
+			a := addr + uint16(i)
+			if i%16 == 0 {
+				if i > 0 {
+					buf.WriteByte('\n')
+				}
+				fmt.Fprintf(&buf, "%04X:", a)
+			}
+			fmt.Fprintf(&buf, " %02X", d.cpu.Peek(a))
+		}
+		buf.WriteByte('\n')
+		d.msgBuffer.Write(buf.Bytes())
+	})
+
+	return nil
+}
+
+func (d *Debugger) consoleDisasm(args string) error {
+	n := 10
+	fields := strings.Fields(args)
+	start := -1
+
+	if len(fields) > 0 {
+		_, addr, err := d.parseAddress(fields[0])
+		if err != nil {
+			return err
+		}
+		start = int(addr)
+	}
+	if len(fields) > 1 {
+		var err error
+		if n, err = strconv.Atoi(fields[1]); err != nil || n < 1 {
+			return fmt.Errorf("invalid instruction count %q", fields[1])
+		}
+	}
+
+	d.runOnCPU(func() {
+		addr := d.cpu.PC
+		if start >= 0 {
+			addr = uint16(start)
+		}
+
+		for i := 0; i < n; i++ {
+			ins, l, h, length := cpu.DecodeAt(d.cpu.Peek, addr)
+			d.msgBuffer.WriteString(fmt.Sprintf("%04X  %s\n", addr, instructionString(ins, l, h, d.cpu.Peek(addr))))
+			addr += length
+		}
+	})
+
+	return nil
+}
+
+// instructionString returns the mnemonic of an instruction, or a data byte
+// for undefined opcodes.
+func instructionString(ins cpu.Instruction, l, h, opcode byte) string {
+	if !ins.Valid() {
+		return fmt.Sprintf("DB $%02X", opcode)
+	}
+
+	return ins.String(l, h)
+}
+
+func (d *Debugger) updateConsoleWindow(g *gocui.Gui) error {
+	v, err := g.View("console")
+	if err != nil {
+		return err
+	}
+
+	if d.console.focused {
+		v.Title = "console (esc to leave, tab to complete)"
+	} else {
+		v.Title = "console (: to type a command)"
+	}
+
+	return nil
+}
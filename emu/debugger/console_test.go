@@ -0,0 +1,51 @@
+package debugger
+
+import (
+	"testing"
+)
+
+func TestConsoleCommands(t *testing.T) {
+	var d Debugger
+	for _, cmd := range []string{"break 0150", "b C000 if A == 1", "wat rw C000-C0FF", "x/4 C000", "s 3", "until 01:4000"} {
+		if err := d.runConsoleCommand(cmd); err != nil {
+			t.Fatalf("%s: %s", cmd, err)
+		}
+	}
+
+	if len(d.breakpoints) != 2 || len(d.watchpoints) != 1 {
+		t.Fatalf("expected 2 breakpoints and 1 watchpoint, got %v and %v", d.breakpoints, d.watchpoints)
+	}
+	if d.stepsLeft != 3 {
+		t.Errorf("expected s to step 3 instructions, got %d", d.stepsLeft)
+	}
+	if d.stepToPC != 0x4000 || d.flowState != FlowStepToPC {
+		t.Errorf("expected to run until 4000, got %04X", d.stepToPC)
+	}
+	if len(d.cpuActions) != 1 {
+		t.Errorf("expected x to queue 1 CPU action, got %d", len(d.cpuActions))
+	}
+
+	for _, cmd := range []string{"nope", "x/0 C000", "step -1", "set Q=1", "set A"} {
+		if err := d.runConsoleCommand(cmd); err == nil {
+			t.Errorf("%s: expected an error", cmd)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	cases := []struct {
+		strs     []string
+		expected string
+	}{
+		{[]string{"step"}, "step"},
+		{[]string{"set", "step"}, "s"},
+		{[]string{"watch", "wait", "war"}, "wa"},
+		{[]string{"break", "x"}, ""},
+	}
+
+	for _, c := range cases {
+		if actual := commonPrefix(c.strs); actual != c.expected {
+			t.Errorf("%v: expected %q, got %q", c.strs, c.expected, actual)
+		}
+	}
+}
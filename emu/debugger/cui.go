@@ -10,6 +10,50 @@ import (
 	"github.com/L-P/poussin/emu/cpu"
 )
 
+// viewRect is the name and position of a view.
+type viewRect struct {
+	name string
+	x1   int
+	y1   int
+	x2   int
+	y2   int
+}
+
+// allSidePanels are the tool views that are only shown when in use.
+var allSidePanels = []string{"call stack", "breakpoints", "watchpoints", "RAM search"}
+
+// sidePanels returns the tool views to show.
+func (d *Debugger) sidePanels() []string {
+	d.Lock()
+	defer d.Unlock()
+
+	var panels []string
+	if len(d.callStack) > 0 || d.callStackMismatchCount > 0 {
+		panels = append(panels, "call stack")
+	}
+	if len(d.breakpoints) > 0 {
+		panels = append(panels, "breakpoints")
+	}
+	if len(d.watchpoints) > 0 {
+		panels = append(panels, "watchpoints")
+	}
+	if d.ramSearch.active {
+		panels = append(panels, "RAM search")
+	}
+
+	return panels
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (d *Debugger) layout(g *gocui.Gui) error {
 	maxX, maxY := g.Size()
 
@@ -17,26 +61,49 @@ func (d *Debugger) layout(g *gocui.Gui) error {
 	msgW := maxX - (iW * 2) - 1
 	msgH := 10
 	memW := 16
+	if len(d.symbols.sorted) > 0 {
+		memW = 32
+	}
+	consoleH := 2
+	disasmW := 32
+
+	// Bottom of the views above the console.
+	topH := maxY - msgH - consoleH - 1
 
-	views := []struct {
-		name string
-		x1   int
-		y1   int
-		x2   int
-		y2   int
-	}{
+	// Tool panels are stacked in a column left of the memory view.
+	panels := d.sidePanels()
+	sideW := 0
+	if len(panels) > 0 {
+		sideW = 24
+	}
+
+	views := []viewRect{
 		{
 			"instructions",
 			0,
 			0,
-			maxX - memW - 1,
-			maxY - msgH - 1,
+			maxX - memW - sideW - disasmW - 2,
+			topH,
+		},
+		{
+			"disassembly",
+			maxX - memW - sideW - disasmW - 1,
+			0,
+			maxX - memW - sideW - 1,
+			topH,
 		},
 		{
 			"memory",
 			maxX - memW,
 			0,
 			maxX - 1,
+			topH,
+		},
+		{
+			"console",
+			0,
+			topH + 1,
+			maxX - 1,
 			maxY - msgH - 1,
 		},
 		{
@@ -62,6 +129,25 @@ func (d *Debugger) layout(g *gocui.Gui) error {
 		},
 	}
 
+	for i, name := range panels {
+		panelH := (topH + 1) / len(panels)
+		views = append(views, viewRect{
+			name,
+			maxX - memW - sideW,
+			i * panelH,
+			maxX - memW - 1,
+			(i+1)*panelH - 1,
+		})
+	}
+
+	for _, name := range allSidePanels {
+		if !containsString(panels, name) {
+			if err := g.DeleteView(name); err != nil && err != gocui.ErrUnknownView {
+				return err
+			}
+		}
+	}
+
 	for _, v := range views {
 		if view, err := g.SetView(v.name, v.x1, v.y1, v.x2, v.y2); err != nil {
 			if err != gocui.ErrUnknownView {
@@ -77,6 +163,8 @@ func (d *Debugger) layout(g *gocui.Gui) error {
 			case "messages":
 				view.Autoscroll = true
 				view.Wrap = true
+			case "console":
+				view.Editable = true
 			}
 		}
 	}
@@ -89,8 +177,13 @@ func (d *Debugger) updateGUI(g *gocui.Gui) error {
 	defer d.Unlock()
 
 	if !d.hasModal.IsSet() {
-		g.SetCurrentView("instructions")
+		if d.console.focused {
+			g.SetCurrentView("console")
+		} else {
+			g.SetCurrentView("instructions")
+		}
 	}
+	g.Cursor = d.console.focused && !d.hasModal.IsSet()
 
 	if err := d.updateMiscWindow(g); err != nil {
 		return err
@@ -112,6 +205,30 @@ func (d *Debugger) updateGUI(g *gocui.Gui) error {
 		return err
 	}
 
+	if err := d.updateRAMSearchWindow(g); err != nil {
+		return err
+	}
+
+	if err := d.updateBreakpointsWindow(g); err != nil {
+		return err
+	}
+
+	if err := d.updateWatchpointsWindow(g); err != nil {
+		return err
+	}
+
+	if err := d.updateCallStackWindow(g); err != nil {
+		return err
+	}
+
+	if err := d.updateConsoleWindow(g); err != nil {
+		return err
+	}
+
+	if err := d.updateDisassemblyWindow(g); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -206,9 +323,13 @@ func (d *Debugger) updateMemoryWindow(g *gocui.Gui) error {
 
 		pc := uint16(d.memBuffer[i]) | (uint16(d.memBuffer[i+1]) << 8)
 		addr := uint16(d.memBuffer[i+3]) | (uint16(d.memBuffer[i+4]) << 8)
-		val := d.memBuffer[i+5]
+		val := d.memBuffer[i+6]
 
-		fmt.Fprintf(v, "%04X %c %04X %02X\n", pc, rwFlag, addr, val)
+		fmt.Fprintf(v, "%04X %c %04X %02X", pc, rwFlag, addr, val)
+		if label := d.symbols.describe(anyBank, addr); label != "" {
+			fmt.Fprintf(v, " %s", label)
+		}
+		fmt.Fprintln(v)
 	}
 
 	return nil
@@ -240,6 +361,12 @@ func (d *Debugger) updateInsWindow(g *gocui.Gui) error {
 			continue
 		}
 
+		addr := uint16(d.insBuffer[i+12+4]) | uint16(d.insBuffer[i+12+5])<<8
+		bank := int(int8(d.insBuffer[i+12+6]))
+		if label, ok := d.symbols.lookup(bank, addr); ok {
+			fmt.Fprintf(view, "\x1b[1;33m%s:\x1b[0m\n", label)
+		}
+
 		d.printInstruction(view, ins, l, h, registers, prevRegisters)
 		prevRegisters = registers
 	}
@@ -313,7 +440,9 @@ func inputModalView(g *gocui.Gui, title string) (*gocui.View, error) {
 	return v, nil
 }
 
-func (d *Debugger) inputUIntModal(g *gocui.Gui, title string, intWidth int, cb func(int64)) error {
+// inputModal asks for a line of text, the modal stays open if cb returns an
+// error.
+func (d *Debugger) inputModal(g *gocui.Gui, title string, cb func(string) error) error {
 	if d.hasModal.IsSet() {
 		return nil
 	}
@@ -327,22 +456,20 @@ func (d *Debugger) inputUIntModal(g *gocui.Gui, title string, intWidth int, cb f
 		title,
 		gocui.KeyEnter,
 		gocui.ModNone,
-		func(g *gocui.Gui, v *gocui.View) error {
+		d.keybindWrapper(func(g *gocui.Gui, v *gocui.View) error {
 			buf := strings.Trim(v.Buffer(), "\n ")
 
 			if buf != "" {
-				s, err := strconv.ParseUint(buf, 16, intWidth)
-				if err != nil {
+				if err := cb(buf); err != nil {
 					d.msgBuffer.WriteString(err.Error() + "\n")
 					return nil
 				}
-				cb(int64(s))
 			}
 
 			d.hasModal.UnSet()
 			g.DeleteKeybindings(title)
 			return g.DeleteView(title)
-		},
+		}),
 	); err != nil {
 		return err
 	}
@@ -350,10 +477,17 @@ func (d *Debugger) inputUIntModal(g *gocui.Gui, title string, intWidth int, cb f
 	return nil
 }
 
-func (d *Debugger) inputUInt8Modal(g *gocui.Gui, title string, cb func(byte)) error {
-	return d.inputUIntModal(g, title, 8, func(v int64) { cb(byte(v)) })
+func (d *Debugger) inputUIntModal(g *gocui.Gui, title string, intWidth int, cb func(int64)) error {
+	return d.inputModal(g, title, func(buf string) error {
+		s, err := strconv.ParseUint(buf, 16, intWidth)
+		if err != nil {
+			return err
+		}
+		cb(int64(s))
+		return nil
+	})
 }
 
-func (d *Debugger) inputUInt16Modal(g *gocui.Gui, title string, cb func(uint16)) error {
-	return d.inputUIntModal(g, title, 16, func(v int64) { cb(uint16(v)) })
+func (d *Debugger) inputUInt8Modal(g *gocui.Gui, title string, cb func(byte)) error {
+	return d.inputUIntModal(g, title, 8, func(v int64) { cb(byte(v)) })
 }
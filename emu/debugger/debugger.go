@@ -14,12 +14,12 @@ import (
 	"github.com/tevino/abool"
 )
 
-// registers + opcode + CB + low arg + high arg
-const insBufferStride = 12 + 4
+// registers + opcode + CB + low arg + high arg + address + bank
+const insBufferStride = 12 + 4 + 2 + 1
 const insBufferCount = 128
 
-// PC + r/w + addr + value
-const memBufferStride = 2 + 1 + 2 + 1
+// PC + r/w + addr + old value + value
+const memBufferStride = 2 + 1 + 2 + 1 + 1
 const memBufferCount = 128
 
 // Debugger is a CLI for debugging a GameBoy ROM execution.
@@ -41,9 +41,9 @@ type Debugger struct {
 	stepToPC       uint16
 	stopWhenSB     byte
 	stepToOpcode   byte
+	stepsLeft      int
 	requestedDepth int
-	// _will_ be negative, nothing prevents you from pushing the stack and RET without having a CALL
-	callDepth int
+	callDepth      int
 
 	// View buffers
 	insBuffer              [insBufferStride * insBufferCount]byte
@@ -53,6 +53,24 @@ type Debugger struct {
 	msgBuffer              bytes.Buffer
 	lastCPUError           error
 
+	// Actions touching the CPU requested by the GUI, they are run by the
+	// emulation routine between two instructions.
+	cpuActions []func()
+
+	// Tools
+	console         console
+	disassembly     disassembly
+	ramSearch       ramSearch
+	breakpoints     []breakpoint
+	breakpointsPath string
+	watchpoints     []watchpoint
+	symbols         symbols
+
+	// Shadow call stack
+	callStack              []frame
+	callStackMismatches    []string
+	callStackMismatchCount int
+
 	// I/O registers
 	ioIF          byte
 	ioIE          byte
@@ -108,6 +126,9 @@ const (
 
 	// FlowStopWhenVSync runs until the next vertical sync.
 	FlowStopWhenVSync
+
+	// FlowStepN runs the number of instructions in stepsLeft.
+	FlowStepN
 )
 
 // New creates a new debugger instance.
@@ -125,6 +146,8 @@ func New(c *cpu.CPU, p *ppu.PPU) (*Debugger, error) {
 		closed:    abool.New(),
 		hasModal:  abool.New(),
 		flowState: FlowRun,
+
+		disassembly: disassembly{follow: true},
 	}
 
 	d.gui.SetManagerFunc(d.layout)
@@ -199,6 +222,7 @@ func (d *Debugger) Update() {
 	d.updateMessages()
 	d.updateIORegisters()
 	d.updateMiscCounters()
+	d.checkBreakpoints()
 
 	d.Unlock()
 
@@ -219,8 +243,15 @@ func (d *Debugger) flowControl() {
 		}
 	case FlowStepOver:
 		atomic.StoreInt32(&d.flowState, FlowPause)
+	case FlowStepN:
+		d.Lock()
+		d.stepsLeft--
+		if d.stepsLeft <= 0 {
+			atomic.StoreInt32(&d.flowState, FlowPause)
+		}
+		d.Unlock()
 	case FlowStopWhenSB:
-		if d.cpu.Mem[cpu.IOSB] == d.stopWhenSB {
+		if d.cpu.Serial.SB == d.stopWhenSB {
 			atomic.StoreInt32(&d.flowState, FlowPause)
 		}
 	case FlowStepToPC:
@@ -237,8 +268,37 @@ func (d *Debugger) flowControl() {
 		}
 	}
 
+	d.runCPUActions()
 	for atomic.LoadInt32(&d.flowState) == FlowPause && !d.closed.IsSet() {
 		time.Sleep(50 * time.Millisecond)
+		d.runCPUActions()
+	}
+}
+
+// runOnCPU queues an action to run in the emulation routine, the lock must
+// be held.
+func (d *Debugger) runOnCPU(f func()) {
+	d.cpuActions = append(d.cpuActions, f)
+}
+
+// runCPUActions runs the actions queued by the GUI and refreshes the views
+// it needs decoded from memory.
+func (d *Debugger) runCPUActions() {
+	d.Lock()
+	defer d.Unlock()
+
+	if len(d.cpuActions) > 0 {
+		for _, f := range d.cpuActions {
+			f()
+		}
+		d.cpuActions = nil
+
+		// Show edits made while paused.
+		d.updateIORegisters()
+	}
+
+	if d.disassembly.stale {
+		d.updateDisassembly()
 	}
 }
 
@@ -267,83 +327,48 @@ func (d *Debugger) updateInstructions() {
 	d.insBuffer[d.curInsBufferWriteIndex+12+1] = cb
 	d.insBuffer[d.curInsBufferWriteIndex+12+2] = d.cpu.LastLowArg
 	d.insBuffer[d.curInsBufferWriteIndex+12+3] = d.cpu.LastHighArg
+	d.insBuffer[d.curInsBufferWriteIndex+12+4] = byte(d.cpu.LastPC)
+	d.insBuffer[d.curInsBufferWriteIndex+12+5] = byte(d.cpu.LastPC >> 8)
+	d.insBuffer[d.curInsBufferWriteIndex+12+6] = byte(d.cpu.ROMBank(d.cpu.LastPC))
 	d.curInsBufferWriteIndex = (d.curInsBufferWriteIndex + insBufferStride) % len(d.insBuffer)
 
 	d.updateCallDepth()
 }
 
+// updateCallDepth keeps the shadow call stack in sync with the CALL, RST,
+// RET, and interrupts that just happened.
 func (d *Debugger) updateCallDepth() {
-	if !d.cpu.LastOpcodeWasCB {
+	// LastOpcode is not updated when an interrupt is serviced.
+	if d.cpu.LastCycleWasInterrupt {
+		d.pushFrame(true)
+	} else if !d.cpu.LastOpcodeWasCB {
 		switch d.cpu.LastOpcode {
 		// RST
-		case 0xC7:
-			fallthrough
-		case 0xCF:
-			fallthrough
-		case 0xD7:
-			fallthrough
-		case 0xDF:
-			fallthrough
-		case 0xE7:
-			fallthrough
-		case 0xEF:
-			fallthrough
-		case 0xF7:
-			fallthrough
-		case 0xFF:
-			d.callDepth++
+		case 0xC7, 0xCF, 0xD7, 0xDF, 0xE7, 0xEF, 0xF7, 0xFF:
+			d.pushFrame(false)
 
 		// CALL
-		case 0xC4:
-			fallthrough
-		case 0xCC:
-			fallthrough
-		case 0xD4:
-			fallthrough
-		case 0xDC:
-			if !d.cpu.Jumped {
-				break
+		case 0xC4, 0xCC, 0xD4, 0xDC:
+			if d.cpu.Jumped {
+				d.pushFrame(false)
 			}
-			fallthrough
 		case 0xCD:
-			d.callDepth++
+			d.pushFrame(false)
 
 		// RET
-		case 0xC0:
-			fallthrough
-		case 0xC8:
-			fallthrough
-		case 0xD0:
-			fallthrough
-		case 0xD8:
-			if !d.cpu.Jumped {
-				break
+		case 0xC0, 0xC8, 0xD0, 0xD8:
+			if d.cpu.Jumped {
+				d.popFrame()
 			}
-			fallthrough
-		case 0xC9:
-			fallthrough
-		case 0xD9:
-			d.callDepth--
+		case 0xC9, 0xD9:
+			d.popFrame()
 		}
 	}
 
-	// Interrupts count as one depth
-	if d.cpu.Mem[cpu.IODisableBootROM] == 0x01 {
-		switch d.cpu.PC {
-		case 0x0040:
-			fallthrough
-		case 0x0048:
-			fallthrough
-		case 0x0050:
-			fallthrough
-		case 0x0058:
-			fallthrough
-		case 0x0060:
-			fallthrough
-		case 0x0080:
-			d.callDepth++
-		}
-	}
+	// Return addresses popped without a RET.
+	d.unwindFrames(d.cpu.SP - 1)
+
+	d.callDepth = len(d.callStack)
 }
 
 func (d *Debugger) updateMessages() {
@@ -381,7 +406,7 @@ func (d *Debugger) updateMemory() {
 		panic("garbage in cpu.MemIOBuffer")
 	}
 
-	buf := make([]byte, 6)
+	buf := make([]byte, memBufferStride)
 	for d.cpu.MemIOBuffer.Len() > 0 {
 		n, err := d.cpu.MemIOBuffer.Read(buf)
 		if err != nil {
@@ -394,6 +419,7 @@ func (d *Debugger) updateMemory() {
 		for i, v := range buf {
 			d.memBuffer[d.curMemBufferWriteIndex+i] = v
 		}
+		d.checkWatchpoints(buf)
 
 		d.curMemBufferWriteIndex = (d.curMemBufferWriteIndex + memBufferStride) % len(d.memBuffer)
 	}
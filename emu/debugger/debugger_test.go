@@ -0,0 +1,20 @@
+package debugger
+
+import (
+	"image"
+	"testing"
+
+	"github.com/L-P/poussin/emu/cpu"
+	"github.com/L-P/poussin/emu/ppu"
+)
+
+// newTestDebugger returns a debugger attached to a DMG CPU and its PPU, the
+// emulation is driven by the test.
+func newTestDebugger(t *testing.T) *Debugger {
+	t.Helper()
+
+	p := ppu.New(make(chan *image.RGBA, 1))
+	c := cpu.New(p, nil, false)
+
+	return &Debugger{cpu: &c, ppu: p}
+}
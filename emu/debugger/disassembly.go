@@ -0,0 +1,244 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/L-P/poussin/emu/cpu"
+	"github.com/jroimartin/gocui"
+)
+
+// disassemblyMargin is the number of lines kept below PC before the view
+// scrolls to follow it.
+const disassemblyMargin = 4
+
+// disassembly decodes the instructions from an address, it is refreshed by
+// the emulation routine when the GUI marks it as stale.
+type disassembly struct {
+	// follow scrolls the view to keep PC visible.
+	follow bool
+
+	pc     uint16
+	start  uint16
+	height int
+	lines  []disassemblyLine
+	stale  bool
+
+	// scroll is the number of instructions to scroll by on the next
+	// refresh, negative to scroll up.
+	scroll int
+}
+
+type disassemblyLine struct {
+	bank  int
+	addr  uint16
+	text  string
+	label string
+}
+
+// updateDisassembly decodes the visible instructions, the lock must be held.
+func (d *Debugger) updateDisassembly() {
+	s := &d.disassembly
+	s.stale = false
+	s.pc = d.cpu.PC
+
+	if s.follow && !s.showsPC(d.cpu.PC) {
+		s.start = d.cpu.PC
+	}
+
+	for ; s.scroll > 0; s.scroll-- {
+		_, _, _, length := cpu.DecodeAt(d.cpu.Peek, s.start)
+		s.start += length
+	}
+	for ; s.scroll < 0; s.scroll++ {
+		s.start = previousInstruction(d.cpu.Peek, s.start)
+	}
+
+	s.lines = s.lines[:0]
+	addr := s.start
+	for rows := 0; rows < s.height; rows++ {
+		line := disassemblyLine{
+			bank: d.cpu.ROMBank(addr),
+			addr: addr,
+		}
+		if label, ok := d.symbols.lookup(line.bank, addr); ok {
+			line.label = label
+			rows++
+		}
+
+		ins, l, h, length := cpu.DecodeAt(d.cpu.Peek, addr)
+		line.text = d.symbolicInstruction(ins, l, h, addr, length)
+		s.lines = append(s.lines, line)
+		addr += length
+	}
+}
+
+// symbolicInstruction returns the mnemonic of an instruction with its
+// address argument replaced by a label if there is one.
+func (d *Debugger) symbolicInstruction(ins cpu.Instruction, l, h byte, addr, length uint16) string {
+	opcode := d.cpu.Peek(addr)
+	str := instructionString(ins, l, h, opcode)
+	if !ins.Valid() || opcode == 0xCB {
+		return str
+	}
+
+	var arg string
+	var target uint16
+	switch {
+	case ins.Length == 3:
+		target = uint16(h)<<8 | uint16(l)
+		arg = fmt.Sprintf("$%02X%02X", h, l)
+	case opcode == 0x18 || opcode&0xE7 == 0x20: // JR
+		target = addr + length + uint16(int8(l))
+		arg = fmt.Sprintf("$%02X", l)
+	default:
+		return str
+	}
+
+	label, ok := d.symbols.lookup(d.cpu.ROMBank(target), target)
+	if !ok {
+		return str
+	}
+
+	return strings.Replace(str, arg, label, 1)
+}
+
+// showsPC returns true if PC is visible and not too close to the bottom of
+// the view.
+func (s *disassembly) showsPC(pc uint16) bool {
+	for i, v := range s.lines {
+		if i >= len(s.lines)-disassemblyMargin {
+			break
+		}
+		if v.addr == pc {
+			return true
+		}
+	}
+
+	return false
+}
+
+// previousInstruction guesses where the instruction before addr starts.
+// Instructions are not aligned so decoding backwards is ambiguous, the
+// longest instruction ending exactly at addr is chosen.
+func previousInstruction(fetch func(uint16) byte, addr uint16) uint16 {
+	for n := uint16(3); n > 1; n-- {
+		if _, _, _, length := cpu.DecodeAt(fetch, addr-n); length == n {
+			return addr - n
+		}
+	}
+
+	return addr - 1
+}
+
+// scrollDisassembly scrolls the view by n instructions and stops following
+// PC.
+func (d *Debugger) scrollDisassembly(n int) {
+	d.disassembly.follow = false
+	d.disassembly.scroll += n
+	d.disassembly.stale = true
+}
+
+func (d *Debugger) cbDisassemblyUp(g *gocui.Gui, v *gocui.View) error {
+	d.scrollDisassembly(-1)
+	return nil
+}
+
+func (d *Debugger) cbDisassemblyDown(g *gocui.Gui, v *gocui.View) error {
+	d.scrollDisassembly(1)
+	return nil
+}
+
+func (d *Debugger) cbDisassemblyPageUp(g *gocui.Gui, v *gocui.View) error {
+	d.scrollDisassembly(-d.disassembly.height / 2)
+	return nil
+}
+
+func (d *Debugger) cbDisassemblyPageDown(g *gocui.Gui, v *gocui.View) error {
+	d.scrollDisassembly(d.disassembly.height / 2)
+	return nil
+}
+
+// cbDisassemblyFollow scrolls back to PC.
+func (d *Debugger) cbDisassemblyFollow(g *gocui.Gui, v *gocui.View) error {
+	d.disassembly.follow = true
+	d.disassembly.scroll = 0
+	d.disassembly.lines = nil
+	d.disassembly.stale = true
+
+	return nil
+}
+
+func (d *Debugger) cbDisassemblyGoto(g *gocui.Gui, v *gocui.View) error {
+	if d.hasModal.IsSet() {
+		return nil
+	}
+
+	return d.inputModal(g, "Disassemble at", d.disassembleAt)
+}
+
+// disassembleAt moves the disassembly view to an address.
+func (d *Debugger) disassembleAt(str string) error {
+	_, addr, err := d.parseAddress(str)
+	if err != nil {
+		return err
+	}
+
+	s := &d.disassembly
+	s.follow = false
+	s.start = addr
+	s.scroll = 0
+	s.stale = true
+
+	return nil
+}
+
+func (d *Debugger) updateDisassemblyWindow(g *gocui.Gui) error {
+	v, err := g.View("disassembly")
+	if err != nil {
+		return err
+	}
+
+	_, h := v.Size()
+	s := &d.disassembly
+	s.height = h
+	s.stale = true
+
+	v.Clear()
+	if s.follow {
+		v.Title = "disassembly (PC)"
+	} else {
+		v.Title = "disassembly (. for PC)"
+	}
+
+	for _, line := range s.lines {
+		if line.label != "" {
+			fmt.Fprintf(v, "\x1b[1;33m%s:\x1b[0m\n", line.label)
+		}
+
+		marker := []byte("  ")
+		for _, b := range d.breakpoints {
+			if b.Addr == line.addr && (b.Bank == anyBank || line.bank == anyBank || b.Bank == line.bank) {
+				if b.Enabled {
+					marker[0] = '*'
+				} else if marker[0] != '*' {
+					marker[0] = 'o'
+				}
+			}
+		}
+		if line.addr == s.pc {
+			marker[1] = '>'
+		}
+
+		str := fmt.Sprintf("%s%7s %s", marker, formatAddress(line.bank, line.addr), line.text)
+		switch {
+		case marker[1] == '>':
+			str = "\x1b[1;32m" + str + "\x1b[0m"
+		case marker[0] == '*':
+			str = "\x1b[1;31m" + str + "\x1b[0m"
+		}
+		fmt.Fprintln(v, str)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisassembly(t *testing.T) {
+	d := newTestDebugger(t)
+	copy(d.cpu.ROM[0x150:], []byte{0x00, 0x3E, 0x12, 0xCB, 0x7C, 0xC3, 0x50, 0x01, 0xD3})
+	d.cpu.PC = 0x150
+	d.disassembly = disassembly{follow: true, height: 5}
+
+	d.updateDisassembly()
+	expected := []disassemblyLine{
+		{0, 0x150, "NOP", ""},
+		{0, 0x151, "LD A,$12", ""},
+		{0, 0x153, "BIT 7,H", ""},
+		{0, 0x155, "JP $0150", ""},
+		{0, 0x158, "DB $D3", ""},
+	}
+	if len(d.disassembly.lines) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, d.disassembly.lines)
+	}
+	for i, v := range expected {
+		if d.disassembly.lines[i] != v {
+			t.Errorf("line %d: expected %v, got %v", i, v, d.disassembly.lines[i])
+		}
+	}
+
+	var err error
+	if d.symbols, err = readSymbols(strings.NewReader("00:0150 Start\n"), "game.sym"); err != nil {
+		t.Fatal(err)
+	}
+	d.updateDisassembly()
+	if actual := d.disassembly.lines[0].label; actual != "Start" {
+		t.Errorf("expected the first line to be labeled Start, got %q", actual)
+	}
+	if actual := d.disassembly.lines[3].text; actual != "JP Start" {
+		t.Errorf("expected the jump to use the label, got %q", actual)
+	}
+	if len(d.disassembly.lines) != 4 {
+		t.Errorf("expected the label to take a line, got %d instructions", len(d.disassembly.lines))
+	}
+
+	if err := d.disassembleAt("0155"); err != nil {
+		t.Fatal(err)
+	}
+	d.scrollDisassembly(-2)
+	d.updateDisassembly()
+	if actual := d.disassembly.lines[0].addr; actual != 0x151 {
+		t.Errorf("expected scrolling up to reach 0151, got %04X", actual)
+	}
+}
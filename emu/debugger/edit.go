@@ -0,0 +1,137 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/L-P/poussin/emu/cpu"
+	"github.com/jroimartin/gocui"
+)
+
+// registerSetters are the registers and flags that can be changed with set.
+var registerSetters = map[string]func(c *cpu.CPU, v int){
+	"A":  func(c *cpu.CPU, v int) { c.A = byte(v) },
+	"F":  func(c *cpu.CPU, v int) { c.SetF(byte(v)) },
+	"B":  func(c *cpu.CPU, v int) { c.SetB(byte(v)) },
+	"C":  func(c *cpu.CPU, v int) { c.SetC(byte(v)) },
+	"D":  func(c *cpu.CPU, v int) { c.SetD(byte(v)) },
+	"E":  func(c *cpu.CPU, v int) { c.SetE(byte(v)) },
+	"H":  func(c *cpu.CPU, v int) { c.SetH(byte(v)) },
+	"L":  func(c *cpu.CPU, v int) { c.SetL(byte(v)) },
+	"AF": func(c *cpu.CPU, v int) { c.A = byte(v >> 8); c.SetF(byte(v)) },
+	"BC": func(c *cpu.CPU, v int) { c.BC = uint16(v) },
+	"DE": func(c *cpu.CPU, v int) { c.DE = uint16(v) },
+	"HL": func(c *cpu.CPU, v int) { c.HL = uint16(v) },
+	"SP": func(c *cpu.CPU, v int) { c.SP = uint16(v) },
+	"PC": func(c *cpu.CPU, v int) { c.PC = uint16(v) },
+
+	"ZF":  func(c *cpu.CPU, v int) { c.FlagZero = v != 0 },
+	"NF":  func(c *cpu.CPU, v int) { c.FlagSubstract = v != 0 },
+	"HF":  func(c *cpu.CPU, v int) { c.FlagHalfCarry = v != 0 },
+	"CF":  func(c *cpu.CPU, v int) { c.FlagCarry = v != 0 },
+	"IME": func(c *cpu.CPU, v int) { c.InterruptMaster = v != 0 },
+}
+
+func (d *Debugger) cbEdit(g *gocui.Gui, v *gocui.View) error {
+	if d.hasModal.IsSet() {
+		return nil
+	}
+
+	atomic.StoreInt32(&d.flowState, FlowPause)
+	return d.inputModal(g, "Edit (eg. A=0x10)", d.setCommand)
+}
+
+// setCommand changes the emulator state, cmd is TARGET=EXPR where TARGET is:
+//
+//	A B C D E F H L AF BC DE HL SP PC  a CPU register
+//	ZF NF HF CF IME                    a flag, set if EXPR is not 0
+//	LCDC, IE, etc.                     an I/O register
+//	[EXPR]                             the memory byte at EXPR
+//
+// I/O registers and memory are written through CPU.Write so side effects
+// happen as if the game wrote them.
+func (d *Debugger) setCommand(cmd string) error {
+	fields := strings.SplitN(cmd, "=", 2)
+	if len(fields) != 2 {
+		return errors.New("expected TARGET=EXPR")
+	}
+
+	target := strings.ToUpper(strings.TrimSpace(fields[0]))
+	value, err := parseExpr(fields[1])
+	if err != nil {
+		return err
+	}
+
+	if set, ok := registerSetters[target]; ok {
+		d.runOnCPU(func() {
+			v := value(d)
+			set(d.cpu, v)
+			d.msgBuffer.WriteString(fmt.Sprintf("%s=%X\n", target, v))
+		})
+		return nil
+	}
+
+	var addr expr
+	if ioAddr, ok := ioRegisterNames[target]; ok {
+		addr = func(*Debugger) int { return int(ioAddr) }
+	} else if strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]") {
+		if addr, err = parseExpr(target[1 : len(target)-1]); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("unknown target %q, expected one of %s, an I/O register, or [ADDR]", target, registerNames())
+	}
+
+	d.runOnCPU(func() {
+		a, v := uint16(addr(d)), byte(value(d))
+		d.cpu.Write(a, v)
+		d.msgBuffer.WriteString(fmt.Sprintf("%04X=%02X\n", a, d.cpu.Peek(a)))
+	})
+
+	return nil
+}
+
+func registerNames() string {
+	names := make([]string, 0, len(registerSetters))
+	for k := range registerSetters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, " ")
+}
+
+// pokeCommand writes bytes to memory bypassing the hardware, cmd is
+// "AAAA VV [VV...]" in hex. See cpu.Poke.
+func (d *Debugger) pokeCommand(cmd string) error {
+	fields := strings.Fields(cmd)
+	if len(fields) < 2 {
+		return errors.New("expected AAAA VV [VV...]")
+	}
+
+	_, addr, err := d.parseAddress(fields[0])
+	if err != nil {
+		return err
+	}
+
+	values := make([]byte, len(fields)-1)
+	for i, v := range fields[1:] {
+		b, err := strconv.ParseUint(v, 16, 8)
+		if err != nil {
+			return fmt.Errorf("invalid byte %q", v)
+		}
+		values[i] = byte(b)
+	}
+
+	d.runOnCPU(func() {
+		for i, v := range values {
+			d.cpu.Poke(addr+uint16(i), v)
+		}
+	})
+
+	return nil
+}
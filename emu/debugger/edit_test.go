@@ -0,0 +1,59 @@
+package debugger
+
+import (
+	"testing"
+)
+
+func TestSetCommand(t *testing.T) {
+	d := newTestDebugger(t)
+	c := d.cpu
+	c.HL = 0xC000
+	c.InternalDIV = 0x1234
+
+	for _, cmd := range []string{"a=0x10", "BC = $BEEF", "cf=1", "IME=1", "[hl]=A + 1", "[$C001]=[HL]", "DIV=3"} {
+		if err := d.setCommand(cmd); err != nil {
+			t.Fatalf("%s: %s", cmd, err)
+		}
+		d.runCPUActions()
+	}
+
+	if c.A != 0x10 || c.BC != 0xBEEF || !c.FlagCarry || !c.InterruptMaster {
+		t.Errorf("registers not set: %s", c.Registers.String())
+	}
+	if c.Mem[0xC000] != 0x11 || c.Mem[0xC001] != 0x11 {
+		t.Errorf("expected C000-C001 to be 11, got %02X %02X", c.Mem[0xC000], c.Mem[0xC001])
+	}
+	if c.InternalDIV != 0 {
+		t.Errorf("expected writing DIV to reset it, got %04X", c.InternalDIV)
+	}
+
+	for _, cmd := range []string{"Q=1", "A", "[C000=1", "A=B+"} {
+		if err := d.setCommand(cmd); err == nil {
+			t.Errorf("%s: expected an error", cmd)
+		}
+	}
+}
+
+func TestPokeCommand(t *testing.T) {
+	d := newTestDebugger(t)
+	c := d.cpu
+	c.InternalDIV = 0x1234
+
+	for _, cmd := range []string{"0150 AF C9", "FF04 42"} {
+		if err := d.pokeCommand(cmd); err != nil {
+			t.Fatalf("%s: %s", cmd, err)
+		}
+	}
+	d.runCPUActions()
+
+	if c.ROM[0x150] != 0xAF || c.ROM[0x151] != 0xC9 {
+		t.Errorf("expected ROM to be patched, got %02X %02X", c.ROM[0x150], c.ROM[0x151])
+	}
+	if c.InternalDIV != 0x4200 {
+		t.Errorf("expected DIV to be 42 without reset, got %04X", c.InternalDIV)
+	}
+
+	if err := d.pokeCommand("C000 100"); err == nil {
+		t.Error("expected an error for an out of range byte")
+	}
+}
@@ -0,0 +1,370 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+// expr is a compiled expression evaluated against the emulator state, eg.
+// A == 0x3F && [HL] > 10 && LY == 144. Booleans are 0 or 1.
+type expr func(d *Debugger) int
+
+// ioRegisterNames maps the I/O register names usable in expressions to their
+// address.
+var ioRegisterNames = map[string]uint16{
+	"P1":   cpu.IOP1,
+	"SB":   cpu.IOSB,
+	"SC":   cpu.IOSC,
+	"DIV":  cpu.IODIV,
+	"TIMA": cpu.IOTIMA,
+	"TMA":  cpu.IOTMA,
+	"TAC":  cpu.IOTAC,
+	"IF":   cpu.IOIF,
+	"LCDC": 0xFF40,
+	"STAT": 0xFF41,
+	"SCY":  0xFF42,
+	"SCX":  0xFF43,
+	"LY":   0xFF44,
+	"LYC":  0xFF45,
+	"DMA":  0xFF46,
+	"BGP":  0xFF47,
+	"OBP0": 0xFF48,
+	"OBP1": 0xFF49,
+	"WY":   0xFF4A,
+	"WX":   0xFF4B,
+	"KEY1": cpu.IOKEY1,
+	"VBK":  0xFF4F,
+	"SVBK": cpu.IOSVBK,
+	"IE":   0xFFFF,
+}
+
+// exprVariables are the registers, flags, and counters usable in
+// expressions.
+var exprVariables = map[string]expr{
+	"A":  func(d *Debugger) int { return int(d.cpu.A) },
+	"F":  func(d *Debugger) int { return int(d.cpu.GetF()) },
+	"B":  func(d *Debugger) int { return int(d.cpu.GetB()) },
+	"C":  func(d *Debugger) int { return int(d.cpu.GetC()) },
+	"D":  func(d *Debugger) int { return int(d.cpu.GetD()) },
+	"E":  func(d *Debugger) int { return int(d.cpu.GetE()) },
+	"H":  func(d *Debugger) int { return int(d.cpu.GetH()) },
+	"L":  func(d *Debugger) int { return int(d.cpu.GetL()) },
+	"AF": func(d *Debugger) int { return int(d.cpu.A)<<8 | int(d.cpu.GetF()) },
+	"BC": func(d *Debugger) int { return int(d.cpu.BC) },
+	"DE": func(d *Debugger) int { return int(d.cpu.DE) },
+	"HL": func(d *Debugger) int { return int(d.cpu.HL) },
+	"SP": func(d *Debugger) int { return int(d.cpu.SP) },
+	"PC": func(d *Debugger) int { return int(d.cpu.PC) },
+
+	"ZF": func(d *Debugger) int { return boolToInt(d.cpu.FlagZero) },
+	"NF": func(d *Debugger) int { return boolToInt(d.cpu.FlagSubstract) },
+	"HF": func(d *Debugger) int { return boolToInt(d.cpu.FlagHalfCarry) },
+	"CF": func(d *Debugger) int { return boolToInt(d.cpu.FlagCarry) },
+
+	"IME":    func(d *Debugger) int { return boolToInt(d.cpu.InterruptMaster) },
+	"CYCLES": func(d *Debugger) int { return d.cpu.Cycle },
+	"FRAMES": func(d *Debugger) int { return d.ppu.PushedFrames },
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// binaryOperators by precedence, lowest first, in the same order as C so
+// A & 0x0F == 0x0F is A & (0x0F == 0x0F).
+var binaryOperators = []struct {
+	ops []string
+
+	// comparison operators do not chain, A < B < C is an error.
+	comparison bool
+}{
+	{ops: []string{"||"}},
+	{ops: []string{"&&"}},
+	{ops: []string{"|"}},
+	{ops: []string{"^"}},
+	{ops: []string{"&"}},
+	{ops: []string{"==", "!=", "<=", ">=", "<", ">"}, comparison: true},
+	{ops: []string{"<<", ">>"}},
+	{ops: []string{"+", "-"}},
+	{ops: []string{"*", "/", "%"}},
+}
+
+var twoCharOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<<", ">>"}
+
+// parseExpr compiles an expression. Numbers are decimal unless prefixed by
+// 0x or $, [addr] reads a byte from memory.
+func parseExpr(src string) (expr, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := exprParser{tokens: tokens}
+	e, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in expression", p.tokens[p.pos])
+	}
+
+	return e, nil
+}
+
+func tokenize(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isIdentChar(c) || c == '$':
+			j := i + 1
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		case i+1 < len(src) && containsString(twoCharOperators, src[i:i+2]):
+			tokens = append(tokens, src[i:i+2])
+			i += 2
+		case strings.IndexByte("+-*/%&|^!~<>[]()", c) >= 0:
+			tokens = append(tokens, src[i:i+1])
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q in expression", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) expect(tok string) error {
+	if p.peek() != tok {
+		return fmt.Errorf("expected %q in expression", tok)
+	}
+	p.pos++
+
+	return nil
+}
+
+func (p *exprParser) binary(level int) (expr, error) {
+	if level >= len(binaryOperators) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for containsString(binaryOperators[level].ops, p.peek()) {
+		op := p.tokens[p.pos]
+		p.pos++
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr(op, left, right)
+
+		if binaryOperators[level].comparison {
+			break
+		}
+	}
+
+	return left, nil
+}
+
+func binaryExpr(op string, l, r expr) expr {
+	switch op {
+	case "||":
+		return func(d *Debugger) int { return boolToInt(l(d) != 0 || r(d) != 0) }
+	case "&&":
+		return func(d *Debugger) int { return boolToInt(l(d) != 0 && r(d) != 0) }
+	case "==":
+		return func(d *Debugger) int { return boolToInt(l(d) == r(d)) }
+	case "!=":
+		return func(d *Debugger) int { return boolToInt(l(d) != r(d)) }
+	case "<=":
+		return func(d *Debugger) int { return boolToInt(l(d) <= r(d)) }
+	case ">=":
+		return func(d *Debugger) int { return boolToInt(l(d) >= r(d)) }
+	case "<":
+		return func(d *Debugger) int { return boolToInt(l(d) < r(d)) }
+	case ">":
+		return func(d *Debugger) int { return boolToInt(l(d) > r(d)) }
+	case "+":
+		return func(d *Debugger) int { return l(d) + r(d) }
+	case "-":
+		return func(d *Debugger) int { return l(d) - r(d) }
+	case "|":
+		return func(d *Debugger) int { return l(d) | r(d) }
+	case "^":
+		return func(d *Debugger) int { return l(d) ^ r(d) }
+	case "*":
+		return func(d *Debugger) int { return l(d) * r(d) }
+	case "&":
+		return func(d *Debugger) int { return l(d) & r(d) }
+	case "<<":
+		return func(d *Debugger) int { return l(d) << uint(r(d)&0x1F) }
+	case ">>":
+		return func(d *Debugger) int { return l(d) >> uint(r(d)&0x1F) }
+	case "/", "%":
+		return func(d *Debugger) int {
+			v := r(d)
+			if v == 0 {
+				return 0
+			}
+			if op == "/" {
+				return l(d) / v
+			}
+			return l(d) % v
+		}
+	}
+
+	panic("unreachable")
+}
+
+func (p *exprParser) unary() (expr, error) {
+	switch p.peek() {
+	case "!", "-", "~":
+		op := p.tokens[p.pos]
+		p.pos++
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "!":
+			return func(d *Debugger) int { return boolToInt(e(d) == 0) }, nil
+		case "-":
+			return func(d *Debugger) int { return -e(d) }, nil
+		default:
+			return func(d *Debugger) int { return ^e(d) }, nil
+		}
+	}
+
+	return p.primary()
+}
+
+func (p *exprParser) primary() (expr, error) {
+	tok := p.peek()
+	p.pos++
+
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "(":
+		e, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case "[":
+		e, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return func(d *Debugger) int { return int(d.cpu.Peek(uint16(e(d)))) }, p.expect("]")
+	}
+
+	if v, ok := parseNumber(tok); ok {
+		return func(*Debugger) int { return v }, nil
+	}
+
+	name := strings.ToUpper(tok)
+	if e, ok := exprVariables[name]; ok {
+		return e, nil
+	}
+	if addr, ok := ioRegisterNames[name]; ok {
+		return func(d *Debugger) int { return int(d.cpu.Peek(addr)) }, nil
+	}
+
+	return nil, fmt.Errorf("unknown name %q in expression", tok)
+}
+
+func parseNumber(tok string) (int, bool) {
+	base := 10
+	switch {
+	case strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0X"):
+		tok, base = tok[2:], 16
+	case strings.HasPrefix(tok, "$"):
+		tok, base = tok[1:], 16
+	}
+
+	v, err := strconv.ParseInt(tok, base, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return int(v), true
+}
+
+// traceFormat is a tracepoint message, expressions between braces are
+// replaced by their value in hex.
+type traceFormat struct {
+	literals []string
+	exprs    []expr
+}
+
+func parseTraceFormat(src string) (traceFormat, error) {
+	var f traceFormat
+	for {
+		start := strings.Index(src, "{")
+		if start < 0 {
+			f.literals = append(f.literals, src)
+			return f, nil
+		}
+
+		end := strings.Index(src[start:], "}")
+		if end < 0 {
+			return f, fmt.Errorf("unclosed { in trace message")
+		}
+
+		e, err := parseExpr(src[start+1 : start+end])
+		if err != nil {
+			return f, err
+		}
+
+		f.literals = append(f.literals, src[:start])
+		f.exprs = append(f.exprs, e)
+		src = src[start+end+1:]
+	}
+}
+
+func (f traceFormat) format(d *Debugger) string {
+	var str string
+	for i, v := range f.literals {
+		str += v
+		if i < len(f.exprs) {
+			str += fmt.Sprintf("%X", f.exprs[i](d))
+		}
+	}
+
+	return str
+}
@@ -0,0 +1,61 @@
+package debugger
+
+import (
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	d := newTestDebugger(t)
+	c := d.cpu
+	c.A = 0x3F
+	c.HL = 0xC000
+	c.Mem[0xC000] = 11
+	c.FlagCarry = true
+
+	tests := []struct {
+		src      string
+		expected int
+	}{
+		{"A == 0x3F && [HL] > 10", 1},
+		{"a == $3F && [hl] > 11", 0},
+		{"(1 + 2) * 3 - 4 / 2", 7},
+		{"!CF || ZF", 0},
+		{"[HL + 1] == 0 && (H << 8 | L) == HL", 1},
+		{"-1 < 0", 1},
+
+		// C precedence
+		{"1 | 2 ^ 3 & 1", 3},
+		{"A & 0x0F == 0x0F", 1},
+		{"(A & 0x0F) == 0x0F", 1},
+		{"A & 0xF0 == 0x30", 0},
+		{"1 + 2 << 1", 6},
+		{"8 >> 1 < 5", 1},
+		{"2 + 3 * 4 % 5", 4},
+		{"A == 0x3F | 0", 1},
+	}
+
+	for _, v := range tests {
+		e, err := parseExpr(v.src)
+		if err != nil {
+			t.Errorf("%s: %s", v.src, err)
+			continue
+		}
+		if actual := e(d); actual != v.expected {
+			t.Errorf("%s: expected %d, got %d", v.src, v.expected, actual)
+		}
+	}
+
+	for _, src := range []string{"A ==", "[HL", "FOO", "A # 2", "1 < 2 < 3"} {
+		if _, err := parseExpr(src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+
+	f, err := parseTraceFormat("A={A} HL={HL}!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str := f.format(d); str != "A=3F HL=C000!" {
+		t.Errorf("unexpected trace message %q", str)
+	}
+}
@@ -23,6 +23,14 @@ func (d *Debugger) initKeybinds() error {
 		{d.cbStopWhenSB, 'o'},
 		{d.cbStopWhenInterrupt, 'y'},
 		{d.cbStopWhenVSync, 'f'},
+		{d.cbToggleCheat, 'c'},
+		{d.cbRAMSearch, 'r'},
+		{d.cbBreakpoint, 'b'},
+		{d.cbWatchpoint, 'w'},
+		{d.cbEdit, 'e'},
+		{d.cbFocusConsole, ':'},
+		{d.cbDisassemblyGoto, 'g'},
+		{d.cbDisassemblyFollow, '.'},
 	}
 
 	for _, v := range binds {
@@ -36,6 +44,49 @@ func (d *Debugger) initKeybinds() error {
 		}
 	}
 
+	disassemblyBinds := []struct {
+		handler keybindHandler
+		key     gocui.Key
+	}{
+		{d.cbDisassemblyUp, gocui.KeyArrowUp},
+		{d.cbDisassemblyDown, gocui.KeyArrowDown},
+		{d.cbDisassemblyPageUp, gocui.KeyPgup},
+		{d.cbDisassemblyPageDown, gocui.KeyPgdn},
+	}
+
+	for _, v := range disassemblyBinds {
+		if err := d.gui.SetKeybinding(
+			"instructions",
+			v.key,
+			gocui.ModNone,
+			d.keybindWrapper(v.handler),
+		); err != nil {
+			return err
+		}
+	}
+
+	consoleBinds := []struct {
+		handler keybindHandler
+		key     gocui.Key
+	}{
+		{d.cbConsoleEnter, gocui.KeyEnter},
+		{d.cbUnfocusConsole, gocui.KeyEsc},
+		{d.cbConsoleComplete, gocui.KeyTab},
+		{d.cbConsoleHistoryUp, gocui.KeyArrowUp},
+		{d.cbConsoleHistoryDown, gocui.KeyArrowDown},
+	}
+
+	for _, v := range consoleBinds {
+		if err := d.gui.SetKeybinding(
+			"console",
+			v.key,
+			gocui.ModNone,
+			d.keybindWrapper(v.handler),
+		); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -48,10 +99,13 @@ func (d *Debugger) keybindWrapper(h keybindHandler) keybindHandler {
 }
 
 func (d *Debugger) cbStepOut(g *gocui.Gui, v *gocui.View) error {
-	if atomic.LoadInt32(&d.flowState) == FlowPause {
-		atomic.StoreInt32(&d.flowState, FlowStepOut)
+	if atomic.LoadInt32(&d.flowState) != FlowPause {
+		return nil
+	}
+
+	if err := d.stepOut(); err != nil {
+		d.msgBuffer.WriteString(err.Error() + "\n")
 	}
-	d.requestedDepth = d.callDepth - 1
 
 	return nil
 }
@@ -98,13 +152,19 @@ func (d *Debugger) cbStepToPC(g *gocui.Gui, v *gocui.View) error {
 		return nil
 	}
 
-	cb := func(addr uint16) {
+	cb := func(str string) error {
+		_, addr, err := d.parseAddress(str)
+		if err != nil {
+			return err
+		}
+
 		d.stepToPC = addr
 		atomic.StoreInt32(&d.flowState, FlowStepToPC)
+		return nil
 	}
 
 	atomic.StoreInt32(&d.flowState, FlowPause)
-	if err := d.inputUInt16Modal(g, "Jump to PC", cb); err != nil {
+	if err := d.inputModal(g, "Jump to PC", cb); err != nil {
 		return err
 	}
 
@@ -0,0 +1,211 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/L-P/poussin/emu/cheat"
+	"github.com/jroimartin/gocui"
+)
+
+// ramSearchRanges are the memory ranges a game can write its state to: SRAM,
+// WRAM and HRAM.
+var ramSearchRanges = [...]struct{ start, end int }{
+	{0xA000, 0xC000},
+	{0xC000, 0xE000},
+	{0xFF80, 0xFFFF},
+}
+
+// ramSearchMaxLines is the number of candidates listed in the view.
+const ramSearchMaxLines = 64
+
+// ramSearch narrows down the addresses holding a value by comparing
+// successive snapshots of the RAM.
+type ramSearch struct {
+	active bool
+
+	// wide compares 16-bit little-endian words instead of bytes.
+	wide bool
+
+	snapshot   [0x10000]byte
+	candidates []uint16
+}
+
+// ramFilter returns true if an address should stay a candidate.
+type ramFilter func(prev, cur int) bool
+
+var ramFilters = map[string]ramFilter{
+	"changed":   func(prev, cur int) bool { return prev != cur },
+	"unchanged": func(prev, cur int) bool { return prev == cur },
+	"increased": func(prev, cur int) bool { return cur > prev },
+	"decreased": func(prev, cur int) bool { return cur < prev },
+}
+
+// reset makes every address a candidate.
+func (s *ramSearch) reset(mem []byte, wide bool) {
+	s.active = true
+	s.wide = wide
+	s.candidates = s.candidates[:0]
+
+	for _, r := range ramSearchRanges {
+		end := r.end
+		if wide {
+			end--
+		}
+		for addr := r.start; addr < end; addr++ {
+			s.candidates = append(s.candidates, uint16(addr))
+		}
+	}
+
+	s.take(mem)
+}
+
+// filter removes the candidates not matching f and takes a new snapshot.
+func (s *ramSearch) filter(mem []byte, f ramFilter) {
+	kept := s.candidates[:0]
+	for _, addr := range s.candidates {
+		if f(s.value(s.snapshot[:], addr), s.value(mem, addr)) {
+			kept = append(kept, addr)
+		}
+	}
+	s.candidates = kept
+
+	s.take(mem)
+}
+
+func (s *ramSearch) take(mem []byte) {
+	for _, r := range ramSearchRanges {
+		copy(s.snapshot[r.start:r.end], mem[r.start:r.end])
+	}
+}
+
+func (s *ramSearch) value(mem []byte, addr uint16) int {
+	if s.wide {
+		return int(mem[addr]) | int(mem[addr+1])<<8
+	}
+
+	return int(mem[addr])
+}
+
+func (d *Debugger) cbRAMSearch(g *gocui.Gui, v *gocui.View) error {
+	if d.hasModal.IsSet() {
+		return nil
+	}
+
+	atomic.StoreInt32(&d.flowState, FlowPause)
+	return d.inputModal(g, "RAM search command", d.ramSearchCommand)
+}
+
+// ramSearchCommand runs a RAM search command:
+//
+//	new, new16          start a 8 or 16-bit search
+//	changed, unchanged  compare with the previous snapshot
+//	increased, decreased
+//	= N                 keep addresses holding N (hex)
+//	freeze I            turn the Ith result into a GameShark code
+//	clear               close the search
+func (d *Debugger) ramSearchCommand(cmd string) error {
+	fields := strings.Fields(strings.Replace(cmd, "=", " = ", 1))
+	if len(fields) == 0 {
+		return errors.New("expected a RAM search command")
+	}
+	s := &d.ramSearch
+
+	switch fields[0] {
+	case "new", "new16":
+		wide := fields[0] == "new16"
+		d.runOnCPU(func() { s.reset(d.cpu.Mem[:], wide) })
+		return nil
+	case "clear":
+		s.active = false
+		s.candidates = nil
+		return nil
+	}
+
+	if !s.active {
+		return errors.New("no RAM search running, start one with new or new16")
+	}
+
+	if f, ok := ramFilters[fields[0]]; ok {
+		d.runOnCPU(func() { s.filter(d.cpu.Mem[:], f) })
+		return nil
+	}
+
+	if len(fields) != 2 {
+		return fmt.Errorf("unknown RAM search command %q", cmd)
+	}
+
+	n, err := strconv.ParseUint(fields[1], 16, 16)
+	if err != nil {
+		return err
+	}
+
+	switch fields[0] {
+	case "=":
+		d.runOnCPU(func() {
+			s.filter(d.cpu.Mem[:], func(_, cur int) bool { return cur == int(n) })
+		})
+	case "freeze":
+		if int(n) >= len(s.candidates) {
+			return fmt.Errorf("no RAM search result #%X", n)
+		}
+		d.freeze(s.candidates[n])
+	default:
+		return fmt.Errorf("unknown RAM search command %q", cmd)
+	}
+
+	return nil
+}
+
+// freeze adds GameShark codes keeping the value of the snapshot at addr.
+func (d *Debugger) freeze(addr uint16) {
+	s := &d.ramSearch
+	size := 1
+	if s.wide {
+		size = 2
+	}
+
+	var codes cheat.List
+	for i := 0; i < size; i++ {
+		a := addr + uint16(i)
+		c, err := cheat.Parse(fmt.Sprintf("01%02X%02X%02X", s.snapshot[a], a&0xFF, a>>8))
+		if err != nil {
+			panic(err)
+		}
+		c.Name = "RAM search"
+		codes = append(codes, c)
+		d.msgBuffer.WriteString(fmt.Sprintf("added cheat %s\n", c.Code))
+	}
+
+	d.runOnCPU(func() { d.cpu.Cheats = append(d.cpu.Cheats, codes...) })
+}
+
+func (d *Debugger) updateRAMSearchWindow(g *gocui.Gui) error {
+	v, err := g.View("RAM search")
+	if err != nil {
+		if err == gocui.ErrUnknownView {
+			return nil
+		}
+		return err
+	}
+	v.Clear()
+
+	s := &d.ramSearch
+	fmt.Fprintf(v, "%d results\n", len(s.candidates))
+	for i, addr := range s.candidates {
+		if i >= ramSearchMaxLines {
+			break
+		}
+
+		if s.wide {
+			fmt.Fprintf(v, "%02X %04X %04X\n", i, addr, s.value(s.snapshot[:], addr))
+		} else {
+			fmt.Fprintf(v, "%02X %04X %02X\n", i, addr, s.snapshot[addr])
+		}
+	}
+
+	return nil
+}
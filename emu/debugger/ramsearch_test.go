@@ -0,0 +1,69 @@
+package debugger
+
+import "testing"
+
+func TestRAMSearch(t *testing.T) {
+	var mem [0xFFFF]byte
+	var s ramSearch
+
+	mem[0xC010] = 3
+	mem[0xC020] = 3
+	s.reset(mem[:], false)
+
+	mem[0xC010] = 2
+	mem[0xC020] = 4
+	s.filter(mem[:], ramFilters["decreased"])
+	if len(s.candidates) != 1 || s.candidates[0] != 0xC010 {
+		t.Fatalf("expected only C010 to remain, got %X", s.candidates)
+	}
+
+	s.filter(mem[:], func(_, cur int) bool { return cur == 1 })
+	if len(s.candidates) != 0 {
+		t.Fatalf("expected no candidates, got %X", s.candidates)
+	}
+}
+
+func TestRAMSearchWide(t *testing.T) {
+	var mem [0xFFFF]byte
+	var s ramSearch
+
+	s.reset(mem[:], true)
+	mem[0xFF90] = 0x34
+	mem[0xFF91] = 0x12
+	s.filter(mem[:], func(_, cur int) bool { return cur == 0x1234 })
+	if len(s.candidates) != 1 || s.candidates[0] != 0xFF90 {
+		t.Fatalf("expected only FF90 to remain, got %X", s.candidates)
+	}
+}
+
+func TestRAMSearchCommandBlank(t *testing.T) {
+	var d Debugger
+	for _, cmd := range []string{"", "\t"} {
+		if err := d.ramSearchCommand(cmd); err == nil {
+			t.Errorf("%q: expected an error", cmd)
+		}
+	}
+}
+
+func TestRAMSearchCommandEquals(t *testing.T) {
+	for _, cmd := range []string{"= 5", "=5"} {
+		d := newTestDebugger(t)
+		d.cpu.Mem[0xC010] = 5
+		if err := d.ramSearchCommand("new"); err != nil {
+			t.Fatal(err)
+		}
+		d.runCPUActions()
+		if err := d.ramSearchCommand(cmd); err != nil {
+			t.Fatalf("%q: %s", cmd, err)
+		}
+		d.runCPUActions()
+
+		var found bool
+		for _, addr := range d.ramSearch.candidates {
+			found = found || addr == 0xC010
+		}
+		if !found {
+			t.Errorf("%q: expected C010 to remain, got %X", cmd, d.ramSearch.candidates)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+// symbolMaxOffset is how far from a label an address can be to be shown as
+// label+offset.
+const symbolMaxOffset = 0x100
+
+// symbol is a label from a .sym file.
+type symbol struct {
+	Bank int
+	Addr uint16
+	Name string
+}
+
+// symbols maps labels to addresses and back, sorted by address then bank.
+type symbols struct {
+	sorted []symbol
+	byName map[string]symbol
+}
+
+// LoadSymbols reads the labels of a RGBDS or no$gmb .sym file, lines are
+// "BB:AAAA Label". A missing file is not an error.
+func (d *Debugger) LoadSymbols(path string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s, err := readSymbols(f, path)
+	if err != nil {
+		return err
+	}
+	d.symbols = s
+
+	return nil
+}
+
+func readSymbols(r io.Reader, path string) (symbols, error) {
+	s := symbols{byName: make(map[string]symbol)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		str := strings.TrimSpace(scanner.Text())
+		if str == "" || str[0] == ';' || str[0] == '[' {
+			continue
+		}
+
+		sym, err := parseSymbol(str)
+		if err != nil {
+			return s, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+
+		s.sorted = append(s.sorted, sym)
+		s.byName[sym.Name] = sym
+	}
+	if err := scanner.Err(); err != nil {
+		return s, err
+	}
+
+	sort.Slice(s.sorted, func(i, j int) bool {
+		a, b := s.sorted[i], s.sorted[j]
+		if a.Addr != b.Addr {
+			return a.Addr < b.Addr
+		}
+		return a.Bank < b.Bank
+	})
+
+	return s, nil
+}
+
+func parseSymbol(str string) (symbol, error) {
+	fields := strings.Fields(str)
+	if len(fields) < 2 || !strings.Contains(fields[0], ":") {
+		return symbol{}, fmt.Errorf("expected BB:AAAA Label, got %q", str)
+	}
+
+	bank, addr, err := parseHexAddress(fields[0])
+	if err != nil {
+		return symbol{}, err
+	}
+
+	return symbol{Bank: bank, Addr: addr, Name: fields[1]}, nil
+}
+
+// lookup returns the label at an address, bank is ignored outside of ROM.
+func (s *symbols) lookup(bank int, addr uint16) (string, bool) {
+	i := sort.Search(len(s.sorted), func(i int) bool { return s.sorted[i].Addr >= addr })
+	for ; i < len(s.sorted) && s.sorted[i].Addr == addr; i++ {
+		if s.sorted[i].matches(bank) {
+			return s.sorted[i].Name, true
+		}
+	}
+
+	return "", false
+}
+
+// describe returns the label at an address or label+offset if the address
+// is shortly after one, an empty string if there is none.
+func (s *symbols) describe(bank int, addr uint16) string {
+	i := sort.Search(len(s.sorted), func(i int) bool { return s.sorted[i].Addr > addr })
+	for i--; i >= 0 && addr-s.sorted[i].Addr < symbolMaxOffset; i-- {
+		sym := s.sorted[i]
+		if !sym.matches(bank) || cpu.AddrToMemType(sym.Addr) != cpu.AddrToMemType(addr) {
+			continue
+		}
+
+		if sym.Addr == addr {
+			return sym.Name
+		}
+		return fmt.Sprintf("%s+%X", sym.Name, addr-sym.Addr)
+	}
+
+	return ""
+}
+
+// matches returns true if the symbol can be at the given bank.
+func (sym symbol) matches(bank int) bool {
+	return bank == anyBank || sym.Addr >= 0x8000 || sym.Bank == bank
+}
+
+// complete returns the labels starting with prefix.
+func (s *symbols) complete(prefix string) []string {
+	var names []string
+	for name := range s.byName {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// parseAddress parses a label or an hex address optionally prefixed by its
+// bank, eg. 01:4000. Labels can be followed by +N, N in hex.
+func (d *Debugger) parseAddress(str string) (int, uint16, error) {
+	name, offset := str, uint64(0)
+	if i := strings.LastIndex(str, "+"); i > 0 {
+		v, err := strconv.ParseUint(str[i+1:], 16, 16)
+		if err == nil {
+			name, offset = str[:i], v
+		}
+	}
+
+	if sym, ok := d.symbols.byName[name]; ok {
+		bank := sym.Bank
+		if sym.Addr >= 0x8000 {
+			bank = anyBank
+		}
+		return bank, sym.Addr + uint16(offset), nil
+	}
+
+	return parseHexAddress(str)
+}
@@ -0,0 +1,92 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSymbols = `; File generated by rgblink
+00:0150 Main
+00:0158 Main.loop
+01:4000 Bank1Func
+02:4000 Bank2Func
+00:C000 wBuffer
+00:C100 wScore
+00:FF80 hDMA
+`
+
+func TestSymbols(t *testing.T) {
+	s, err := readSymbols(strings.NewReader(testSymbols), "game.sym")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Debugger{symbols: s}
+
+	lookups := []struct {
+		bank     int
+		addr     uint16
+		expected string
+	}{
+		{0, 0x150, "Main"},
+		{anyBank, 0x158, "Main.loop"},
+		{1, 0x4000, "Bank1Func"},
+		{2, 0x4000, "Bank2Func"},
+		{3, 0x4000, ""},
+		{anyBank, 0xC000, "wBuffer"},
+		{1, 0xC000, "wBuffer"},
+		{0, 0x151, ""},
+	}
+	for _, v := range lookups {
+		if actual, _ := s.lookup(v.bank, v.addr); actual != v.expected {
+			t.Errorf("lookup %s: expected %q, got %q", formatAddress(v.bank, v.addr), v.expected, actual)
+		}
+	}
+
+	descriptions := []struct {
+		addr     uint16
+		expected string
+	}{
+		{0xC000, "wBuffer"},
+		{0xC0FF, "wBuffer+FF"},
+		{0xC105, "wScore+5"},
+		{0xC200, ""},
+		{0xFF81, "hDMA+1"},
+		{0xFF7F, ""},
+	}
+	for _, v := range descriptions {
+		if actual := s.describe(anyBank, v.addr); actual != v.expected {
+			t.Errorf("describe %04X: expected %q, got %q", v.addr, v.expected, actual)
+		}
+	}
+
+	addresses := []struct {
+		str  string
+		bank int
+		addr uint16
+	}{
+		{"Main.loop", 0, 0x158},
+		{"Bank2Func", 2, 0x4000},
+		{"wScore+2", anyBank, 0xC102},
+		{"01:4010", 1, 0x4010},
+		{"C000", anyBank, 0xC000},
+	}
+	for _, v := range addresses {
+		bank, addr, err := d.parseAddress(v.str)
+		if err != nil {
+			t.Errorf("%s: %s", v.str, err)
+		} else if bank != v.bank || addr != v.addr {
+			t.Errorf("%s: expected %s, got %s", v.str, formatAddress(v.bank, v.addr), formatAddress(bank, addr))
+		}
+	}
+
+	if _, _, err := d.parseAddress("Nope"); err == nil {
+		t.Error("expected an unknown label to be an error")
+	}
+	if actual := s.complete("Ma"); strings.Join(actual, " ") != "Main Main.loop" {
+		t.Errorf("expected Main and Main.loop, got %v", actual)
+	}
+
+	if _, err := readSymbols(strings.NewReader("0150 Main\n"), "game.sym"); err == nil {
+		t.Error("expected a missing bank to be an error")
+	}
+}
@@ -0,0 +1,209 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jroimartin/gocui"
+)
+
+// Memory accesses as logged in cpu.MemIOBuffer.
+const (
+	accessRead  = 0x01
+	accessWrite = 0x02
+)
+
+// watchpoint pauses the execution after an instruction accessed memory
+// between Start and End included.
+type watchpoint struct {
+	Start, End  uint16
+	Read, Write bool
+
+	// Value only triggers the watchpoint on writes changing the value to
+	// it, -1 for any write.
+	Value int
+
+	Enabled bool
+	Hits    int
+}
+
+func (w watchpoint) String() string {
+	var str string
+	if w.Read {
+		str += "r"
+	}
+	if w.Write {
+		str += "w"
+	}
+
+	str += fmt.Sprintf(" %04X", w.Start)
+	if w.End != w.Start {
+		str += fmt.Sprintf("-%04X", w.End)
+	}
+	if w.Value >= 0 {
+		str += fmt.Sprintf("=%02X", w.Value)
+	}
+	if !w.Enabled {
+		str += " off"
+	}
+
+	return str + fmt.Sprintf(" %d", w.Hits)
+}
+
+// matches returns true if the access triggers the watchpoint.
+func (w watchpoint) matches(rw byte, addr uint16, old, value byte) bool {
+	if !w.Enabled || addr < w.Start || addr > w.End {
+		return false
+	}
+
+	switch rw {
+	case accessRead:
+		return w.Read
+	case accessWrite:
+		return w.Write && (w.Value < 0 || (int(value) == w.Value && old != value))
+	}
+
+	return false
+}
+
+// checkWatchpoints pauses the execution if an entry of cpu.MemIOBuffer
+// triggers a watchpoint.
+func (d *Debugger) checkWatchpoints(entry []byte) {
+	if len(d.watchpoints) == 0 {
+		return
+	}
+
+	pc := uint16(entry[0]) | uint16(entry[1])<<8
+	rw := entry[2]
+	addr := uint16(entry[3]) | uint16(entry[4])<<8
+	old, value := entry[5], entry[6]
+
+	for i := range d.watchpoints {
+		w := &d.watchpoints[i]
+		if !w.matches(rw, addr, old, value) {
+			continue
+		}
+
+		w.Hits++
+		atomic.StoreInt32(&d.flowState, FlowPause)
+		if rw == accessRead {
+			d.msgBuffer.WriteString(fmt.Sprintf(
+				"watchpoint #%X: %04X read %04X=%02X\n", i, pc, addr, value,
+			))
+		} else {
+			d.msgBuffer.WriteString(fmt.Sprintf(
+				"watchpoint #%X: %04X wrote %04X=%02X, was %02X\n", i, pc, addr, value, old,
+			))
+		}
+	}
+}
+
+func (d *Debugger) cbWatchpoint(g *gocui.Gui, v *gocui.View) error {
+	if d.hasModal.IsSet() {
+		return nil
+	}
+
+	return d.inputModal(g, "Watchpoint", d.watchpointCommand)
+}
+
+// watchpointCommand runs a watchpoint command:
+//
+//	r|w|rw AAAA[-AAAA] [= VV]  add a watchpoint, optionally only triggered
+//	                           by writes changing the value to VV
+//	toggle I                   enable or disable the Ith watchpoint
+//	delete I                   remove the Ith watchpoint
+func (d *Debugger) watchpointCommand(cmd string) error {
+	fields := strings.Fields(strings.Replace(cmd, "=", " = ", 1))
+	if len(fields) < 2 {
+		return fmt.Errorf("unknown watchpoint command %q", cmd)
+	}
+
+	switch fields[0] {
+	case "toggle", "delete":
+		i, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil {
+			return err
+		}
+		if int(i) >= len(d.watchpoints) {
+			return fmt.Errorf("no watchpoint #%X", i)
+		}
+
+		if fields[0] == "toggle" {
+			d.watchpoints[i].Enabled = !d.watchpoints[i].Enabled
+		} else {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+		}
+		return nil
+	}
+
+	w, err := d.parseWatchpoint(fields)
+	if err != nil {
+		return err
+	}
+	d.watchpoints = append(d.watchpoints, w)
+
+	return nil
+}
+
+func (d *Debugger) parseWatchpoint(fields []string) (watchpoint, error) {
+	w := watchpoint{
+		Read:    strings.Contains(fields[0], "r"),
+		Write:   strings.Contains(fields[0], "w"),
+		Value:   -1,
+		Enabled: true,
+	}
+	if strings.Trim(fields[0], "rw") != "" || (!w.Read && !w.Write) {
+		return w, fmt.Errorf("invalid watchpoint access %q, expected r, w, or rw", fields[0])
+	}
+
+	bounds := strings.SplitN(fields[1], "-", 2)
+	_, start, err := d.parseAddress(bounds[0])
+	if err != nil {
+		return w, err
+	}
+	w.Start, w.End = start, start
+
+	if len(bounds) > 1 {
+		_, end, err := d.parseAddress(bounds[1])
+		if err != nil {
+			return w, err
+		}
+		if end < w.Start {
+			return w, fmt.Errorf("invalid watchpoint range %s", fields[1])
+		}
+		w.End = end
+	}
+
+	switch {
+	case len(fields) == 2:
+	case len(fields) == 4 && fields[2] == "=" && w.Write:
+		v, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil {
+			return w, err
+		}
+		w.Value = int(v)
+	default:
+		return w, fmt.Errorf("invalid watchpoint %q", strings.Join(fields, " "))
+	}
+
+	return w, nil
+}
+
+func (d *Debugger) updateWatchpointsWindow(g *gocui.Gui) error {
+	v, err := g.View("watchpoints")
+	if err != nil {
+		if err == gocui.ErrUnknownView {
+			return nil
+		}
+		return err
+	}
+	v.Clear()
+
+	for i, w := range d.watchpoints {
+		fmt.Fprintf(v, "%02X %s\n", i, w)
+	}
+
+	return nil
+}
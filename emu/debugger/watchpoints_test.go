@@ -0,0 +1,90 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+func TestWatchpoints(t *testing.T) {
+	var d Debugger
+	for _, cmd := range []string{"w C000-C0FF", "rw FF80", "w D000=03"} {
+		if err := d.watchpointCommand(cmd); err != nil {
+			t.Fatalf("%s: %s", cmd, err)
+		}
+	}
+
+	tests := []struct {
+		entry    []byte
+		expected int
+	}{
+		{[]byte{0x50, 0x01, accessRead, 0x10, 0xC0, 0x00, 0x00}, -1},
+		{[]byte{0x50, 0x01, accessWrite, 0x10, 0xC0, 0x00, 0x01}, 0},
+		{[]byte{0x50, 0x01, accessRead, 0x80, 0xFF, 0x00, 0x00}, 1},
+		{[]byte{0x50, 0x01, accessWrite, 0x00, 0xD0, 0x03, 0x03}, -1},
+		{[]byte{0x50, 0x01, accessWrite, 0x00, 0xD0, 0x02, 0x03}, 2},
+	}
+
+	for _, v := range tests {
+		before := make([]int, len(d.watchpoints))
+		for i, w := range d.watchpoints {
+			before[i] = w.Hits
+		}
+
+		d.checkWatchpoints(v.entry)
+		for i, w := range d.watchpoints {
+			hit := w.Hits != before[i]
+			if hit != (i == v.expected) {
+				t.Errorf("%X: watchpoint #%d hit: %t", v.entry, i, hit)
+			}
+		}
+	}
+
+	if err := d.watchpointCommand("x C000"); err == nil {
+		t.Error("expected an error on an invalid access")
+	}
+}
+
+func TestWatchpointPC(t *testing.T) {
+	d := newTestDebugger(t)
+	c := d.cpu
+	c.EnableDebug = true
+	c.Mem[cpu.IODisableBootROM] = 1
+	copy(c.ROM[0x150:], []byte{0x77, 0xCD, 0x00, 0x40}) // LD (HL),A; CALL $4000
+	c.PC = 0x150
+	c.SP = 0xDFFE
+	c.HL = 0xC000
+	c.A = 0x42
+	if err := d.watchpointCommand("w C000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.watchpointCommand("w DFF8-DFFD"); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		interrupt bool
+		expected  string
+	}{
+		{false, "watchpoint #0: 0150 wrote C000=42, was 00\n"},
+		{false, "watchpoint #1: 0151 wrote DFFD=01, was 00\n"},
+		{true, "watchpoint #1: 4000 wrote DFFB=40, was 00\n"},
+	}
+	for _, v := range steps {
+		if v.interrupt {
+			c.InterruptMaster = true
+			c.WriteIE(cpu.IEVBlank)
+			c.SetIF(cpu.IEVBlank)
+		}
+		d.msgBuffer.Reset()
+		if _, err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+		d.updateMemory()
+
+		if actual := d.msgBuffer.String(); !strings.HasPrefix(actual, v.expected) {
+			t.Errorf("expected %q, got %q", v.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+// Package disasm turns a ROM into an assembly listing RGBDS can build back
+// into the same ROM.
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+// BankSize is the size of a ROM bank.
+const BankSize = 0x4000
+
+// bytesPerDataLine is the number of bytes in a db directive.
+const bytesPerDataLine = 8
+
+// entryPoints are where the hardware starts running code.
+var entryPoints = []struct {
+	addr uint16
+	name string
+}{
+	{0x0100, "EntryPoint"},
+	{0x0040, "VBlankInterrupt"},
+	{0x0048, "LCDCInterrupt"},
+	{0x0050, "TimerInterrupt"},
+	{0x0058, "SerialInterrupt"},
+	{0x0060, "JoypadInterrupt"},
+}
+
+// Program is a ROM where the code reachable from the entry points has been
+// told apart from data.
+type Program struct {
+	rom []byte
+
+	// code is true for the offsets starting an instruction, covered for
+	// every byte of an instruction.
+	code    []bool
+	covered []bool
+
+	labels map[int]string
+	queue  []target
+}
+
+// target is code left to trace, romx is the bank assumed to be mapped at
+// 4000-7FFF when tracing bank 0.
+type target struct {
+	offset int
+	romx   int
+}
+
+// Analyze follows the control flow of a ROM from its entry point and
+// interrupt vectors.
+func Analyze(rom []byte) *Program {
+	p := &Program{
+		rom:     rom,
+		code:    make([]bool, len(rom)),
+		covered: make([]bool, len(rom)),
+		labels:  make(map[int]string),
+	}
+
+	for _, v := range entryPoints {
+		if int(v.addr) < len(rom) {
+			p.labels[int(v.addr)] = v.name
+			p.queue = append(p.queue, target{int(v.addr), 1})
+		}
+	}
+
+	for len(p.queue) > 0 {
+		t := p.queue[len(p.queue)-1]
+		p.queue = p.queue[:len(p.queue)-1]
+		p.trace(t)
+	}
+
+	return p
+}
+
+// Banks returns the number of banks in the ROM.
+func (p *Program) Banks() int {
+	return (len(p.rom) + BankSize - 1) / BankSize
+}
+
+// IsCode returns true if an instruction starts at the given ROM offset.
+func (p *Program) IsCode(offset int) bool {
+	return offset >= 0 && offset < len(p.code) && p.code[offset]
+}
+
+// Label returns the label of a ROM offset, if any.
+func (p *Program) Label(offset int) (string, bool) {
+	name, ok := p.labels[offset]
+	return name, ok
+}
+
+// Offset returns the ROM offset of an address in a bank.
+func Offset(bank int, addr uint16) int {
+	if bank == 0 {
+		return int(addr)
+	}
+
+	return bank*BankSize + int(addr) - BankSize
+}
+
+// address returns the bank and address of a ROM offset.
+func address(offset int) (int, uint16) {
+	bank := offset / BankSize
+	if bank == 0 {
+		return 0, uint16(offset)
+	}
+
+	return bank, uint16(BankSize + offset%BankSize)
+}
+
+// resolve returns the ROM offset of an address jumped to from offset, or -1
+// if it is not in ROM.
+func (p *Program) resolve(from int, romx int, addr uint16) int {
+	var offset int
+	switch {
+	case addr < BankSize:
+		offset = int(addr)
+	case addr < 2*BankSize:
+		bank, _ := address(from)
+		if bank == 0 {
+			bank = romx
+		}
+		offset = Offset(bank, addr)
+	default:
+		return -1
+	}
+
+	if offset >= len(p.rom) {
+		return -1
+	}
+
+	return offset
+}
+
+// decode decodes the instruction at a ROM offset.
+func (p *Program) decode(offset int) (ins cpu.Instruction, l, h byte, length int) {
+	fetch := func(addr uint16) byte {
+		if i := offset + int(addr); i < len(p.rom) {
+			return p.rom[i]
+		}
+		return 0
+	}
+
+	ins, l, h, n := cpu.DecodeAt(fetch, 0)
+	return ins, l, h, int(n)
+}
+
+func (p *Program) trace(t target) {
+	offset, romx := t.offset, t.romx
+
+	// Value of A when set by an immediate, to follow bank switches.
+	lastA := -1
+
+	for offset < len(p.rom) && !p.covered[offset] {
+		ins, l, h, length := p.decode(offset)
+		if !ins.Valid() || offset%BankSize+length > BankSize || offset+length > len(p.rom) {
+			return
+		}
+		for i := offset; i < offset+length; i++ {
+			if p.covered[i] {
+				return
+			}
+		}
+
+		p.code[offset] = true
+		for i := offset; i < offset+length; i++ {
+			p.covered[i] = true
+		}
+
+		_, addr := address(offset)
+		next := addr + uint16(length)
+		opcode := p.rom[offset]
+		if opcode == 0xCB {
+			opcode = 0
+		}
+
+		switch opcode {
+		case 0x18, 0x20, 0x28, 0x30, 0x38: // JR
+			p.jump(offset, romx, next+uint16(int8(l)), "Jump")
+		case 0xC2, 0xC3, 0xCA, 0xD2, 0xDA: // JP
+			p.jump(offset, romx, uint16(h)<<8|uint16(l), "Jump")
+		case 0xC4, 0xCC, 0xCD, 0xD4, 0xDC: // CALL
+			p.jump(offset, romx, uint16(h)<<8|uint16(l), "Call")
+		case 0xC7, 0xCF, 0xD7, 0xDF, 0xE7, 0xEF, 0xF7, 0xFF: // RST
+			p.jump(offset, romx, uint16(opcode&0x38), "Call")
+		case 0x3E: // LD A,n
+			lastA = int(l)
+		case 0xEA: // LD (nn),A, switches banks when writing to 2000-3FFF
+			if dst := uint16(h)<<8 | uint16(l); dst >= 0x2000 && dst < 0x4000 && lastA >= 0 {
+				romx = lastA
+				if romx == 0 {
+					romx = 1
+				}
+			}
+		}
+
+		if opcode != 0x3E && opcode != 0xE0 && opcode != 0xEA {
+			lastA = -1
+		}
+
+		switch opcode {
+		case 0x18, 0xC3, 0xC9, 0xD9, 0xE9: // JR, JP, RET, RETI, JP (HL)
+			return
+		}
+
+		offset += length
+	}
+}
+
+// jump queues code to trace and labels it.
+func (p *Program) jump(from int, romx int, addr uint16, kind string) {
+	offset := p.resolve(from, romx, addr)
+	if offset < 0 {
+		return
+	}
+
+	if name, ok := p.labels[offset]; !ok || (kind == "Call" && strings.HasPrefix(name, "Jump_")) {
+		bank, addr := address(offset)
+		p.labels[offset] = fmt.Sprintf("%s_%03X_%04X", kind, bank, addr)
+	}
+
+	p.queue = append(p.queue, target{offset, romx})
+}
+
+// WriteASM writes the RGBDS source of the program, one section per bank.
+func (p *Program) WriteASM(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for bank := 0; bank < p.Banks(); bank++ {
+		if bank == 0 {
+			fmt.Fprintf(bw, "SECTION \"ROM Bank $%03X\", ROM0[$0000]\n", bank)
+		} else {
+			fmt.Fprintf(bw, "\nSECTION \"ROM Bank $%03X\", ROMX[$4000], BANK[$%X]\n", bank, bank)
+		}
+
+		end := (bank + 1) * BankSize
+		if end > len(p.rom) {
+			end = len(p.rom)
+		}
+		p.writeBank(bw, bank*BankSize, end)
+	}
+
+	return bw.Flush()
+}
+
+func (p *Program) writeBank(w io.Writer, start, end int) {
+	var data []string
+	flush := func() {
+		if len(data) > 0 {
+			fmt.Fprintf(w, "\tdb %s\n", strings.Join(data, ", "))
+			data = data[:0]
+		}
+	}
+
+	for offset := start; offset < end; {
+		if name, ok := p.labels[offset]; ok && p.code[offset] {
+			flush()
+			fmt.Fprintf(w, "\n%s:\n", name)
+		}
+
+		if p.code[offset] {
+			_, _, _, length := p.decode(offset)
+			if str, ok := p.instruction(offset); ok {
+				flush()
+				fmt.Fprintf(w, "\t%s\n", str)
+			} else {
+				// No RGBDS syntax assembles to these exact bytes.
+				for i := offset; i < offset+length; i++ {
+					data = append(data, fmt.Sprintf("$%02X", p.rom[i]))
+				}
+				flush()
+			}
+			offset += length
+			continue
+		}
+
+		data = append(data, fmt.Sprintf("$%02X", p.rom[offset]))
+		if len(data) == bytesPerDataLine {
+			flush()
+		}
+		offset++
+	}
+
+	flush()
+}
+
+// instruction returns the RGBDS syntax of the instruction at offset, false
+// if it has to be written as data to be assembled to the same bytes.
+func (p *Program) instruction(offset int) (string, bool) {
+	ins, l, h, length := p.decode(offset)
+	_, addr := address(offset)
+	nn := uint16(h)<<8 | uint16(l)
+	opcode := p.rom[offset]
+
+	if opcode == 0xCB {
+		return brackets(ins.String(l, h)), true
+	}
+
+	switch opcode {
+	case 0x10: // STOP is always followed by 00
+		return "STOP", l == 0x00
+	case 0xEA, 0xFA: // RGBDS may optimize LD (FFnn) to LDH
+		if h == 0xFF {
+			return "", false
+		}
+	case 0x18, 0x20, 0x28, 0x30, 0x38:
+		return branchPrefix(ins) + p.reference(offset, addr+uint16(length)+uint16(int8(l))), true
+	case 0xC2, 0xC3, 0xCA, 0xD2, 0xDA, 0xC4, 0xCC, 0xCD, 0xD4, 0xDC:
+		return branchPrefix(ins) + p.reference(offset, nn), true
+	case 0xE8:
+		return fmt.Sprintf("ADD SP,%d", int8(l)), true
+	case 0xF8:
+		return fmt.Sprintf("LD HL,SP%+d", int8(l)), true
+	case 0xE9:
+		return "JP HL", true
+	case 0xE2:
+		return "LD [$FF00+C],A", true
+	case 0xF2:
+		return "LD A,[$FF00+C]", true
+	case 0xE0:
+		return fmt.Sprintf("LDH [$FF%02X],A", l), true
+	case 0xF0:
+		return fmt.Sprintf("LDH A,[$FF%02X]", l), true
+	case 0x22:
+		return "LD [HL+],A", true
+	case 0x2A:
+		return "LD A,[HL+]", true
+	case 0x32:
+		return "LD [HL-],A", true
+	case 0x3A:
+		return "LD A,[HL-]", true
+	}
+
+	return brackets(ins.String(l, h)), true
+}
+
+// branchPrefix returns the mnemonic and condition of a jump or call, eg.
+// "JP NZ,".
+func branchPrefix(ins cpu.Instruction) string {
+	return ins.Name[:strings.Index(ins.Name, "$")]
+}
+
+// reference returns the label of an address jumped to from offset, or the
+// address if it has none.
+func (p *Program) reference(from int, addr uint16) string {
+	// The bank assumed when tracing is not kept, use the label of any bank
+	// if there is only one.
+	if addr >= BankSize && addr < 2*BankSize {
+		if bank, _ := address(from); bank == 0 {
+			var found string
+			for b := 1; b < p.Banks(); b++ {
+				if name, ok := p.labels[Offset(b, addr)]; ok && p.code[Offset(b, addr)] {
+					if found != "" {
+						return fmt.Sprintf("$%04X", addr)
+					}
+					found = name
+				}
+			}
+			if found != "" {
+				return found
+			}
+			return fmt.Sprintf("$%04X", addr)
+		}
+	}
+
+	if offset := p.resolve(from, 1, addr); offset >= 0 && p.code[offset] {
+		if name, ok := p.labels[offset]; ok {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("$%04X", addr)
+}
+
+func brackets(str string) string {
+	return strings.NewReplacer("(", "[", ")", "]").Replace(str)
+}
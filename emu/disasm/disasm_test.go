@@ -0,0 +1,57 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	rom := make([]byte, 3*BankSize)
+	copy(rom[0x40:], []byte{0xD9})                    // RETI
+	copy(rom[0x100:], []byte{0x00, 0xC3, 0x50, 0x01}) // NOP, JP $0150
+	copy(rom[0x150:], []byte{
+		0x3E, 0x02, // LD A,2
+		0xEA, 0x00, 0x20, // LD ($2000),A
+		0xCD, 0x00, 0x40, // CALL $4000
+		0x20, 0xF6, // JR NZ,$0150
+		0xF0, 0x44, // LDH A,($44)
+		0xFA, 0x80, 0xFF, // LD A,($FF80)
+		0x10, 0x00, // STOP
+		0x18, 0xFE, // JR $0161
+	})
+	copy(rom[Offset(2, 0x4000):], []byte{0xCB, 0x7C, 0x36, 0x12, 0xC9}) // BIT 7,H; LD (HL),$12; RET
+
+	p := Analyze(rom)
+	for _, offset := range []int{0x40, 0x100, 0x101, 0x150, 0x152, 0x155, 0x158, 0x15A, 0x15C, 0x15F, 0x161, 0x8000, 0x8002, 0x8004} {
+		if !p.IsCode(offset) {
+			t.Errorf("expected code at %05X", offset)
+		}
+	}
+	for _, offset := range []int{0x41, 0x104, 0x151, 0x4000, 0x8005} {
+		if p.IsCode(offset) {
+			t.Errorf("expected data at %05X", offset)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteASM(&buf); err != nil {
+		t.Fatal(err)
+	}
+	asm := buf.String()
+
+	for _, expected := range []string{
+		"SECTION \"ROM Bank $000\", ROM0[$0000]\n",
+		"\nVBlankInterrupt:\n\tRETI\n\tdb $00,",
+		"\nEntryPoint:\n\tNOP\n\tJP Jump_000_0150\n",
+		"\nJump_000_0150:\n\tLD A,$02\n\tLD [$2000],A\n\tCALL Call_002_4000\n\tJR NZ,Jump_000_0150\n",
+		"\tLDH A,[$FF44]\n\tdb $FA, $80, $FF\n\tSTOP\n",
+		"\nJump_000_0161:\n\tJR Jump_000_0161\n",
+		"SECTION \"ROM Bank $001\", ROMX[$4000], BANK[$1]\n\tdb $00,",
+		"SECTION \"ROM Bank $002\", ROMX[$4000], BANK[$2]\n\nCall_002_4000:\n\tBIT 7,H\n\tLD [HL],$12\n\tRET\n",
+	} {
+		if !strings.Contains(asm, expected) {
+			t.Errorf("expected listing to contain %q", expected)
+		}
+	}
+}
@@ -3,17 +3,22 @@ package emu
 import (
 	"image"
 
+	"github.com/L-P/poussin/emu/cheat"
 	"github.com/L-P/poussin/emu/cpu"
 	"github.com/L-P/poussin/emu/debugger"
 	"github.com/L-P/poussin/emu/ppu"
 )
 
-// Gameboy is a DMG emulator.
+// Gameboy is a DMG/CGB emulator.
 type Gameboy struct {
 	cpu cpu.CPU
 	ppu *ppu.PPU
 
 	debugger *debugger.Debugger
+
+	// bootAnimation is set when Run should play the boot animation instead
+	// of running a boot ROM.
+	bootAnimation bool
 }
 
 // NewGameboy creates a new Gameboy.
@@ -36,6 +41,60 @@ func NewGameboy(
 	return &gb, nil
 }
 
+// NewHeadlessGameboy creates a Gameboy without debugger, renderer, nor input
+// channel. It is meant to be driven by calling Step.
+func NewHeadlessGameboy() *Gameboy {
+	gb := Gameboy{
+		ppu: ppu.New(nil),
+	}
+
+	gb.cpu = cpu.New(gb.ppu, nil, false)
+
+	return &gb
+}
+
+// SetModel selects the emulated hardware, it must be called before loading
+// any ROM. SGB frames include the border and are sgb.Width by sgb.Height.
+func (g *Gameboy) SetModel(m cpu.Model) {
+	g.cpu.SetModel(m)
+
+	g.ppu.Colorizer = nil
+	if g.cpu.SGB != nil {
+		g.ppu.Colorizer = g.cpu.SGB
+	}
+}
+
+// SetCompatPalettes overrides the palettes the CGB boot ROM picks for DMG
+// games.
+func (g *Gameboy) SetCompatPalettes(palettes ppu.CompatPalettes) {
+	g.cpu.CompatPalettesOverride = &palettes
+}
+
+// SetCheats replaces the Game Genie and GameShark codes, they can be toggled
+// from the debugger.
+func (g *Gameboy) SetCheats(cheats cheat.List) {
+	g.cpu.Cheats = cheats
+}
+
+// LoadBreakpoints restores the debugger breakpoints from a file, they are
+// saved to it when changed.
+func (g *Gameboy) LoadBreakpoints(path string) error {
+	if g.debugger == nil {
+		return nil
+	}
+
+	return g.debugger.LoadBreakpoints(path)
+}
+
+// LoadSymbols loads the labels shown by the debugger from a .sym file.
+func (g *Gameboy) LoadSymbols(path string) error {
+	if g.debugger == nil {
+		return nil
+	}
+
+	return g.debugger.LoadSymbols(path)
+}
+
 // LoadBootROM puts a boot rom in the 256 first bytes or RAM.
 func (g *Gameboy) LoadBootROM(rom []byte) error {
 	return g.cpu.LoadBootROM(rom)
@@ -51,11 +110,14 @@ func (g *Gameboy) Run(shouldClose <-chan bool, closed chan<- bool) {
 	go g.debugger.RunGUI(shouldClose)
 	defer close(closed)
 
-	for !g.debugger.Closed() {
-		cycles, err := g.cpu.Step()
-		for i := 0; i < cycles; i++ {
-			g.ppu.Cycle()
+	if g.bootAnimation {
+		if err := g.PlayBootAnimation(); err != nil {
+			g.debugger.Panic(err)
 		}
+	}
+
+	for !g.debugger.Closed() {
+		_, err := g.Step()
 
 		if g.cpu.EnableDebug {
 			g.debugger.Update()
@@ -71,9 +133,45 @@ func (g *Gameboy) Run(shouldClose <-chan bool, closed chan<- bool) {
 	}
 }
 
+// Step runs the next CPU instruction and the PPU for as long as it took, it
+// returns the number of cycles elapsed.
+func (g *Gameboy) Step() (int, error) {
+	cycles, err := g.cpu.Step()
+
+	// The PPU does not care about double speed.
+	dots := cycles
+	if g.cpu.DoubleSpeed {
+		dots /= 2
+	}
+
+	for i := 0; i < dots; i++ {
+		g.ppu.Cycle()
+	}
+
+	return cycles, err
+}
+
+// SetJoypad changes the state of the joypad buttons, it is an alternative to
+// sending states through the input channel.
+func (g *Gameboy) SetJoypad(state cpu.JoypadState) {
+	g.cpu.SetJoypad(state)
+}
+
+// Frame returns the last frame drawn by the PPU.
+func (g *Gameboy) Frame() *image.RGBA {
+	return g.ppu.FrontBuffer()
+}
+
 // Close frees up all resources used by the emulator.
 func (g *Gameboy) Close() {
-	g.debugger.Close()
+	if g.debugger != nil {
+		g.debugger.Close()
+	}
+}
+
+// ConnectSerial plugs a device in the link port, nil unplugs it.
+func (g *Gameboy) ConnectSerial(peer cpu.SerialPeer) {
+	g.cpu.Serial.Peer = peer
 }
 
 // SimulateBoot puts the CPU in the same state it would be after running the Nintendo boot ROM.
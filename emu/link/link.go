@@ -0,0 +1,264 @@
+// Package link implements a link cable between two emulator instances over
+// TCP.
+//
+// Both ends exchange their cycle count every syncInterval cycles and never run
+// more than syncWindow cycles ahead of each other, this keeps the clocks close
+// enough for the slave (external clock) end to see a transfer at the same time
+// the master (internal clock) end completes it.
+package link
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+const (
+	// Number of cycles between two sync messages.
+	syncInterval = 2048
+
+	// Maximum number of cycles an end can run ahead of the other.
+	syncWindow = 8 * syncInterval
+)
+
+// Sent by both ends when connecting, the last byte is the protocol version.
+var handshake = []byte("POUSSIN\x01")
+
+const (
+	// msgSync tells the remote end our cycle count.
+	msgSync = byte(iota)
+
+	// msgTransfer is sent by the end clocking a transfer, with the byte it
+	// shifted out.
+	msgTransfer
+
+	// msgReply is sent in response to msgTransfer with the byte the end
+	// driven by the external clock shifted out.
+	msgReply
+)
+
+type message struct {
+	Kind  byte
+	Cycle uint64
+	Data  byte
+}
+
+// Link is a cpu.SerialPeer connected to another emulator instance.
+type Link struct {
+	conn net.Conn
+
+	mutex sync.Mutex
+	cond  *sync.Cond
+
+	// Cycle count of this end.
+	cycle    uint64
+	lastSync uint64
+
+	// Last known cycle count of the remote end.
+	remoteCycle uint64
+
+	// Transfers clocked by the remote end, waiting for us to reply.
+	transfers []message
+
+	// Replies to transfers we clocked.
+	replies []byte
+
+	closed bool
+	err    error
+}
+
+// Listen waits for a single link partner to connect on the given address.
+func Listen(addr string) (*Link, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return New(conn)
+}
+
+// Dial connects to a link partner waiting on the given address.
+func Dial(addr string) (*Link, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(conn)
+}
+
+// New creates a Link over an established connection.
+func New(conn net.Conn) (*Link, error) {
+	if _, err := conn.Write(handshake); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	remote := make([]byte, len(handshake))
+	if _, err := io.ReadFull(conn, remote); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !bytes.Equal(remote, handshake) {
+		conn.Close()
+		return nil, errors.New("remote end is not a compatible poussin link")
+	}
+
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(true)
+	}
+
+	l := &Link{conn: conn}
+	l.cond = sync.NewCond(&l.mutex)
+
+	go l.read()
+
+	return l, nil
+}
+
+// Close disconnects the link, the Game Boy will then behave as if nothing
+// was plugged in.
+func (l *Link) Close() error {
+	l.mutex.Lock()
+	l.closed = true
+	l.cond.Broadcast()
+	l.mutex.Unlock()
+
+	return l.conn.Close()
+}
+
+// Err returns the error that closed the link, if any.
+func (l *Link) Err() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.err
+}
+
+// Transfer sends the byte we clocked out and waits for the remote end to
+// reply with its own.
+func (l *Link) Transfer(out byte) byte {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed {
+		return 0xFF
+	}
+
+	l.send(message{msgTransfer, l.cycle, out})
+	for len(l.replies) == 0 && !l.closed {
+		// Both ends clocked a transfer at the same time, the remote
+		// sees our port as not ready.
+		for range l.transfers {
+			l.send(message{msgReply, l.cycle, 0xFF})
+		}
+		l.transfers = l.transfers[:0]
+
+		l.cond.Wait()
+	}
+
+	if l.closed {
+		return 0xFF
+	}
+
+	in := l.replies[0]
+	l.replies = l.replies[1:]
+
+	return in
+}
+
+// Sync keeps both ends clocks in sync and replies to transfers clocked by the
+// remote end once we reach the cycle they happened at.
+func (l *Link) Sync(s *cpu.Serial, cycles int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed {
+		return
+	}
+
+	l.cycle += uint64(cycles)
+
+	for len(l.transfers) > 0 && l.transfers[0].Cycle <= l.cycle {
+		l.send(message{msgReply, l.cycle, s.ExternalTransfer(l.transfers[0].Data)})
+		l.transfers = l.transfers[1:]
+	}
+
+	if l.cycle-l.lastSync >= syncInterval {
+		l.send(message{msgSync, l.cycle, 0})
+	}
+
+	for l.cycle > l.remoteCycle+syncWindow && !l.closed {
+		l.cond.Wait()
+	}
+}
+
+// send writes a message to the remote end, the mutex must be held.
+func (l *Link) send(msg message) {
+	if err := binary.Write(l.conn, binary.BigEndian, msg); err != nil {
+		l.fail(err)
+		return
+	}
+
+	l.lastSync = msg.Cycle
+}
+
+// fail closes the link after an error, the mutex must be held.
+func (l *Link) fail(err error) {
+	if l.closed {
+		return
+	}
+
+	l.err = err
+	l.closed = true
+	l.conn.Close()
+	l.cond.Broadcast()
+}
+
+// read receives messages from the remote end until the connection closes.
+func (l *Link) read() {
+	for {
+		var msg message
+		err := binary.Read(l.conn, binary.BigEndian, &msg)
+
+		l.mutex.Lock()
+		if err != nil {
+			if err != io.EOF && !l.closed {
+				l.fail(err)
+			}
+			l.closed = true
+			l.cond.Broadcast()
+			l.mutex.Unlock()
+			return
+		}
+
+		if msg.Cycle > l.remoteCycle {
+			l.remoteCycle = msg.Cycle
+		}
+
+		switch msg.Kind {
+		case msgSync:
+		case msgTransfer:
+			l.transfers = append(l.transfers, msg)
+		case msgReply:
+			l.replies = append(l.replies, msg.Data)
+		default:
+			l.fail(fmt.Errorf("unknown link message kind: %02X", msg.Kind))
+		}
+
+		l.cond.Broadcast()
+		l.mutex.Unlock()
+	}
+}
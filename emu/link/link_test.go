@@ -0,0 +1,68 @@
+package link
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+func TestLoopbackTransfer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var server *Link
+	accepted := make(chan error)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			server, err = New(conn)
+		}
+		accepted <- err
+	}()
+
+	client, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := <-accepted; err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// The master clocks out 0x42 while the slave waits with 0x99.
+	master := cpu.Serial{Peer: client, SB: 0x42}
+	slave := cpu.Serial{Peer: server, SB: 0x99}
+	master.WriteSC(cpu.SCTransferStart | cpu.SCInternalClock)
+	slave.WriteSC(cpu.SCTransferStart)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	run := func(s *cpu.Serial) {
+		defer wg.Done()
+		for i := 0; i < 100000; i++ {
+			if s.Update(4) {
+				return
+			}
+		}
+		t.Errorf("transfer did not complete")
+	}
+	go run(&master)
+	go run(&slave)
+	wg.Wait()
+
+	if master.SB != 0x99 {
+		t.Errorf("master received %02X, expected 99", master.SB)
+	}
+	if slave.SB != 0x42 {
+		t.Errorf("slave received %02X, expected 42", slave.SB)
+	}
+	if master.Transferring() || slave.Transferring() {
+		t.Errorf("SC transfer flag was not reset")
+	}
+}
@@ -0,0 +1,132 @@
+package emu
+
+import (
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+// LinkedPair runs two headless Gameboy in lockstep in the same process with
+// their link ports wired together. Everything runs on the caller goroutine
+// so the outcome only depends on the ROMs and the inputs given.
+type LinkedPair struct {
+	// Gameboys holds both ends of the link cable.
+	Gameboys [2]*Gameboy
+
+	// Transfers contains every byte exchanged on the link cable.
+	Transfers []LinkTransfer
+}
+
+// LinkTransfer is a byte exchange on the link cable of a LinkedPair.
+type LinkTransfer struct {
+	// Cycle is the CPU cycle the transfer completed at on the clocking end.
+	Cycle int
+
+	// Master is the index of the Gameboy that clocked the transfer.
+	Master int
+
+	// Sent is the byte shifted out by the master, Received is the one it got
+	// back from the other end.
+	Sent     byte
+	Received byte
+}
+
+// wire is the cable end plugged in one Gameboy of a LinkedPair.
+type wire struct {
+	pair  *LinkedPair
+	index int
+}
+
+// NewLinkedPair creates two headless Gameboy connected with a link cable.
+func NewLinkedPair() *LinkedPair {
+	p := LinkedPair{
+		Gameboys: [2]*Gameboy{NewHeadlessGameboy(), NewHeadlessGameboy()},
+	}
+
+	for i, gb := range p.Gameboys {
+		gb.ConnectSerial(&wire{pair: &p, index: i})
+	}
+
+	return &p
+}
+
+// LoadROM loads the same ROM in both Gameboy.
+func (p *LinkedPair) LoadROM(rom []byte) error {
+	for _, gb := range p.Gameboys {
+		if err := gb.LoadROM(rom); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SimulateBoot puts both Gameboy in their post-boot state.
+func (p *LinkedPair) SimulateBoot() {
+	for _, gb := range p.Gameboys {
+		gb.SimulateBoot()
+	}
+}
+
+// Step runs a single instruction on the Gameboy that is behind the other.
+func (p *LinkedPair) Step() error {
+	gb := p.Gameboys[0]
+	if p.Gameboys[1].cpu.Cycle < gb.cpu.Cycle {
+		gb = p.Gameboys[1]
+	}
+
+	_, err := gb.Step()
+
+	return err
+}
+
+// RunCycles runs both Gameboy for at least the given number of cycles.
+func (p *LinkedPair) RunCycles(cycles int) error {
+	target := p.Gameboys[0].cpu.Cycle + cycles
+	if other := p.Gameboys[1].cpu.Cycle + cycles; other > target {
+		target = other
+	}
+
+	for p.Gameboys[0].cpu.Cycle < target || p.Gameboys[1].cpu.Cycle < target {
+		if err := p.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunFrames runs both Gameboy until they each displayed the given number of
+// frames.
+func (p *LinkedPair) RunFrames(frames int) error {
+	var targets [2]int
+	for i, gb := range p.Gameboys {
+		targets[i] = gb.ppu.PushedFrames + frames
+	}
+
+	for p.Gameboys[0].ppu.PushedFrames < targets[0] ||
+		p.Gameboys[1].ppu.PushedFrames < targets[1] {
+		if err := p.Step(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Transfer exchanges the given byte with the other Gameboy serial port.
+func (w *wire) Transfer(out byte) byte {
+	master := w.pair.Gameboys[w.index]
+	other := w.pair.Gameboys[1-w.index]
+	in := other.cpu.Serial.ExternalTransfer(out)
+
+	w.pair.Transfers = append(w.pair.Transfers, LinkTransfer{
+		Cycle:    master.cpu.Cycle,
+		Master:   w.index,
+		Sent:     out,
+		Received: in,
+	})
+
+	return in
+}
+
+// Sync does nothing, the pair is kept in sync by LinkedPair.Step.
+func (w *wire) Sync(*cpu.Serial, int) {}
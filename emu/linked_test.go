@@ -0,0 +1,60 @@
+package emu
+
+import (
+	"bytes"
+	"testing"
+)
+
+// serialROM returns a ROM that sends sb with the given SC value, waits for
+// the transfer to complete, and stores the received byte at 0xFF80.
+func serialROM(sb, sc byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0100:], []byte{
+		0x3E, sb, // LD A,sb
+		0xE0, 0x01, // LDH (SB),A
+		0x3E, sc, // LD A,sc
+		0xE0, 0x02, // LDH (SC),A
+		0xF0, 0x02, // LDH A,(SC)
+		0xE6, 0x80, // AND $80
+		0x20, 0xFA, // JR NZ,-6
+		0xF0, 0x01, // LDH A,(SB)
+		0xE0, 0x80, // LDH ($80),A
+		0x18, 0xFE, // JR -2
+	})
+
+	return rom
+}
+
+func TestLinkedPairTransfer(t *testing.T) {
+	p := NewLinkedPair()
+	if err := p.Gameboys[0].LoadROM(serialROM(0x42, 0x81)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Gameboys[1].LoadROM(serialROM(0x99, 0x80)); err != nil {
+		t.Fatal(err)
+	}
+	p.SimulateBoot()
+
+	if err := p.RunFrames(2); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Transfers) != 1 {
+		t.Fatalf("expected a single transfer, got %v", p.Transfers)
+	}
+	if tr := p.Transfers[0]; tr.Master != 0 || tr.Sent != 0x42 || tr.Received != 0x99 {
+		t.Errorf("unexpected transfer: %+v", tr)
+	}
+
+	if v := p.Gameboys[0].cpu.Fetch(0xFF80); v != 0x99 {
+		t.Errorf("master received %02X, expected 99", v)
+	}
+	if v := p.Gameboys[1].cpu.Fetch(0xFF80); v != 0x42 {
+		t.Errorf("slave received %02X, expected 42", v)
+	}
+
+	// Neither ROM touches VRAM, both should display the same thing.
+	if !bytes.Equal(p.Gameboys[0].Frame().Pix, p.Gameboys[1].Frame().Pix) {
+		t.Errorf("frames differ")
+	}
+}
@@ -0,0 +1,92 @@
+package patch
+
+import "errors"
+
+// BPS actions, stored in the two low bits of each command.
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+var errBPSBounds = errors.New("BPS action out of bounds")
+
+func applyBPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsMagic)+12 {
+		return nil, errTruncated
+	}
+
+	sums := readChecksums(patch)
+	if err := sums.verifySource(rom, patch); err != nil {
+		return nil, err
+	}
+
+	r := newReader(patch, len(bpsMagic), len(patch)-12)
+	sourceSize := r.varint()
+	targetSize := r.varint()
+	r.bytes(r.varint()) // metadata
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != len(rom) {
+		return nil, errors.New("BPS source size does not match the ROM")
+	}
+
+	out := make([]byte, targetSize)
+	var pos, sourceRel, targetRel int
+	for !r.done() {
+		cmd := r.varint()
+		length := cmd>>2 + 1
+		if pos+length > len(out) {
+			return nil, errBPSBounds
+		}
+
+		switch cmd & 0x03 {
+		case bpsSourceRead:
+			if pos+length > len(rom) {
+				return nil, errBPSBounds
+			}
+			copy(out[pos:], rom[pos:pos+length])
+		case bpsTargetRead:
+			copy(out[pos:], r.bytes(length))
+		case bpsSourceCopy:
+			sourceRel += signed(r.varint())
+			if sourceRel < 0 || sourceRel+length > len(rom) {
+				return nil, errBPSBounds
+			}
+			copy(out[pos:], rom[sourceRel:sourceRel+length])
+			sourceRel += length
+		case bpsTargetCopy:
+			targetRel += signed(r.varint())
+			if targetRel < 0 || targetRel >= pos {
+				return nil, errBPSBounds
+			}
+			// Byte by byte as the copy can overlap the bytes being written.
+			for i := 0; i < length; i++ {
+				out[pos+i] = out[targetRel]
+				targetRel++
+			}
+		}
+		pos += length
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := sums.verifyTarget(out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// signed decodes the relative offsets of copy actions, the low bit is the
+// sign.
+func signed(v int) int {
+	if v&1 != 0 {
+		return -(v >> 1)
+	}
+
+	return v >> 1
+}
@@ -0,0 +1,55 @@
+package patch
+
+import "errors"
+
+// IPS records offsets are 24 bits, the patch ends with "EOF" optionally
+// followed by the size to truncate the ROM to.
+var ipsEOF = []byte("EOF")
+
+func applyIPS(rom, patch []byte) ([]byte, error) {
+	out := append([]byte(nil), rom...)
+	r := newReader(patch, len(ipsMagic), len(patch))
+
+	for {
+		b := r.bytes(3)
+		if r.err != nil {
+			return nil, r.err
+		}
+		if string(b) == string(ipsEOF) {
+			break
+		}
+		offset := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+
+		size := int(r.byte())<<8 | int(r.byte())
+		var data []byte
+		if size == 0 { // RLE record
+			size = int(r.byte())<<8 | int(r.byte())
+			v := r.byte()
+			data = make([]byte, size)
+			for i := range data {
+				data[i] = v
+			}
+		} else {
+			data = r.bytes(size)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	if r.end-r.pos == 3 {
+		b := r.bytes(3)
+		size := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		if size > len(out) {
+			return nil, errors.New("IPS truncation past the end of the ROM")
+		}
+		out = out[:size]
+	}
+
+	return out, nil
+}
@@ -0,0 +1,122 @@
+// Package patch applies IPS, UPS, and BPS patches to ROMs.
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+var (
+	ipsMagic = []byte("PATCH")
+	upsMagic = []byte("UPS1")
+	bpsMagic = []byte("BPS1")
+)
+
+// Apply returns a patched copy of rom, the patch format is guessed from its
+// magic bytes.
+func Apply(rom, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, ipsMagic):
+		return applyIPS(rom, patch)
+	case bytes.HasPrefix(patch, upsMagic):
+		return applyUPS(rom, patch)
+	case bytes.HasPrefix(patch, bpsMagic):
+		return applyBPS(rom, patch)
+	}
+
+	return nil, errors.New("unknown patch format, expected IPS, UPS, or BPS")
+}
+
+var errTruncated = errors.New("truncated patch")
+
+// reader reads the patch bytes, it stops at end and sets err instead of
+// panicking on truncated patches.
+type reader struct {
+	data []byte
+	pos  int
+	end  int
+	err  error
+}
+
+func newReader(data []byte, start, end int) *reader {
+	return &reader{data: data, pos: start, end: end}
+}
+
+func (r *reader) done() bool {
+	return r.err != nil || r.pos >= r.end
+}
+
+func (r *reader) byte() byte {
+	if r.pos >= r.end {
+		r.err = errTruncated
+		return 0
+	}
+
+	r.pos++
+	return r.data[r.pos-1]
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n < 0 || r.pos+n > r.end {
+		r.err = errTruncated
+		return nil
+	}
+
+	r.pos += n
+	return r.data[r.pos-n : r.pos]
+}
+
+// varint reads a number in the encoding UPS and BPS use, 7 bits at a time
+// with the high bit set on the last byte.
+func (r *reader) varint() int {
+	var v, shift uint64 = 0, 1
+	for i := 0; i < 10; i++ {
+		x := r.byte()
+		v += uint64(x&0x7F) * shift
+		if x&0x80 != 0 || r.err != nil {
+			return int(v)
+		}
+		shift <<= 7
+		v += shift
+	}
+
+	r.err = errors.New("invalid number in patch")
+	return 0
+}
+
+// checksums are the CRC32s ending UPS and BPS patches.
+type checksums struct {
+	source, target, patch uint32
+}
+
+func readChecksums(patch []byte) checksums {
+	f := patch[len(patch)-12:]
+	le := func(b []byte) uint32 {
+		return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+	}
+
+	return checksums{source: le(f[0:4]), target: le(f[4:8]), patch: le(f[8:12])}
+}
+
+// verifySource checks the patch integrity and that it was made for rom.
+func (c checksums) verifySource(rom, patch []byte) error {
+	if actual := crc32.ChecksumIEEE(patch[:len(patch)-4]); actual != c.patch {
+		return fmt.Errorf("corrupted patch: CRC32 %08X, expected %08X", actual, c.patch)
+	}
+
+	if actual := crc32.ChecksumIEEE(rom); actual != c.source {
+		return fmt.Errorf("patch made for another ROM: CRC32 %08X, expected %08X", actual, c.source)
+	}
+
+	return nil
+}
+
+func (c checksums) verifyTarget(target []byte) error {
+	if actual := crc32.ChecksumIEEE(target); actual != c.target {
+		return fmt.Errorf("patched ROM mismatch: CRC32 %08X, expected %08X", actual, c.target)
+	}
+
+	return nil
+}
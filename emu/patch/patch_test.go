@@ -0,0 +1,106 @@
+package patch
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func varint(v int) []byte {
+	var out []byte
+	for {
+		x := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(out, x|0x80)
+		}
+		out = append(out, x)
+		v--
+	}
+}
+
+// withChecksums appends the UPS/BPS footer to body.
+func withChecksums(body []byte, source, target string) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], crc32.ChecksumIEEE([]byte(source)))
+	body = append(body, b[:]...)
+	binary.LittleEndian.PutUint32(b[:], crc32.ChecksumIEEE([]byte(target)))
+	body = append(body, b[:]...)
+	binary.LittleEndian.PutUint32(b[:], crc32.ChecksumIEEE(body))
+
+	return append(body, b[:]...)
+}
+
+func TestApply(t *testing.T) {
+	ips := []byte("PATCH")
+	ips = append(ips, 0x00, 0x00, 0x00, 0x00, 0x01, 'j')             // "jello"
+	ips = append(ips, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x03, '!') // RLE "!!!"
+	ips = append(ips, "EOF"...)
+
+	ups := append([]byte("UPS1"), varint(5)...)
+	ups = append(ups, varint(5)...)
+	ups = append(ups, varint(0)...)
+	ups = append(ups, 'h'^'j', 0x00)
+	ups = withChecksums(ups, "hello", "jello")
+
+	bps := append([]byte("BPS1"), varint(5)...)
+	bps = append(bps, varint(11)...)
+	bps = append(bps, varint(0)...)
+	bps = append(bps, varint(4<<2|bpsSourceRead)...)
+	bps = append(bps, varint(5<<2|bpsTargetRead)...)
+	bps = append(bps, " world"...)
+	bps = withChecksums(bps, "hello", "hello world")
+
+	tests := []struct {
+		name     string
+		patch    []byte
+		expected string
+	}{
+		{"IPS", ips, "jello!!!"},
+		{"UPS", ups, "jello"},
+		{"BPS", bps, "hello world"},
+	}
+
+	for _, v := range tests {
+		out, err := Apply([]byte("hello"), v.patch)
+		if err != nil {
+			t.Errorf("%s: %s", v.name, err)
+			continue
+		}
+		if string(out) != v.expected {
+			t.Errorf("%s: expected %q, got %q", v.name, v.expected, out)
+		}
+	}
+
+	if _, err := Apply([]byte("world"), bps); err == nil {
+		t.Error("expected a source CRC32 mismatch")
+	}
+	if _, err := Apply([]byte("hello"), ips[:10]); err == nil {
+		t.Error("expected an error on a truncated patch")
+	}
+}
+
+func TestUPSLastByte(t *testing.T) {
+	ups := append([]byte("UPS1"), varint(5)...)
+	ups = append(ups, varint(5)...)
+	ups = append(ups, varint(4)...)
+	ups = append(ups, 'o'^'!', 0x00)
+	ups = withChecksums(ups, "hello", "hell!")
+
+	out, err := Apply([]byte("hello"), ups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "hell!" {
+		t.Errorf("expected %q, got %q", "hell!", out)
+	}
+
+	ups = append([]byte("UPS1"), varint(5)...)
+	ups = append(ups, varint(5)...)
+	ups = append(ups, varint(4)...)
+	ups = append(ups, 'o'^'!', 0x01, 0x00)
+	ups = withChecksums(ups, "hello", "hell!")
+	if _, err := Apply([]byte("hello"), ups); err == nil {
+		t.Error("expected an error on a hunk past the end of the ROM")
+	}
+}
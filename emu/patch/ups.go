@@ -0,0 +1,59 @@
+package patch
+
+import "errors"
+
+// UPS patches XOR the source with runs of non-zero bytes.
+func applyUPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len(upsMagic)+12 {
+		return nil, errTruncated
+	}
+
+	sums := readChecksums(patch)
+	if err := sums.verifySource(rom, patch); err != nil {
+		return nil, err
+	}
+
+	r := newReader(patch, len(upsMagic), len(patch)-12)
+	sourceSize := r.varint()
+	targetSize := r.varint()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != len(rom) {
+		return nil, errors.New("UPS source size does not match the ROM")
+	}
+
+	out := make([]byte, targetSize)
+	copy(out, rom)
+
+	pos := 0
+	for !r.done() {
+		pos += r.varint()
+		for {
+			x := r.byte()
+			if r.err != nil {
+				return nil, r.err
+			}
+			// The terminator of a hunk ending on the last byte is past
+			// the end.
+			if x == 0 {
+				pos++
+				break
+			}
+			if pos >= len(out) {
+				return nil, errors.New("UPS hunk past the end of the ROM")
+			}
+			out[pos] ^= x
+			pos++
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if err := sums.verifyTarget(out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
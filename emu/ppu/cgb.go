@@ -0,0 +1,149 @@
+package ppu
+
+import "image/color"
+
+// CGB registers
+const (
+	// VBK VRAM bank
+	VBK = 0xFF4F
+
+	// BCPS Background color palette specification (index + auto-increment)
+	BCPS = 0xFF68
+
+	// BCPD Background color palette data
+	BCPD = 0xFF69
+
+	// OCPS Object color palette specification (index + auto-increment)
+	OCPS = 0xFF6A
+
+	// OCPD Object color palette data
+	OCPD = 0xFF6B
+
+	// OPRI Object priority mode
+	OPRI = 0xFF6C
+)
+
+// BG map attributes, stored in VRAM bank 1 in CGB mode.
+const (
+	AttrPalette  = 0x07
+	AttrBank     = 1 << 3
+	AttrXFlip    = 1 << 5
+	AttrYFlip    = 1 << 6
+	AttrPriority = 1 << 7
+)
+
+// Returns true if the address is in the range of the CGB PPU registers
+func IsCGBPPUIO(addr uint16) bool {
+	return addr == VBK || (addr >= BCPS && addr <= OPRI)
+}
+
+func (p *PPU) fetchCGBRegister(addr uint16) byte {
+	if !p.CGBMode {
+		return 0xFF
+	}
+
+	switch addr {
+	case VBK:
+		return 0xFE | byte(p.VRAMBank)
+	case BCPS:
+		return p.BCPS | 0x40
+	case BCPD:
+		if !p.VRAMAccessible() {
+			return 0xFF
+		}
+		return p.BGPalettes[p.BCPS&0x3F]
+	case OCPS:
+		return p.OCPS | 0x40
+	case OCPD:
+		if !p.VRAMAccessible() {
+			return 0xFF
+		}
+		return p.OBJPalettes[p.OCPS&0x3F]
+	case OPRI:
+		return p.OPRI | 0xFE
+	}
+
+	panic("unreachable")
+}
+
+func (p *PPU) writeCGBRegister(addr uint16, b byte) {
+	if !p.CGBMode {
+		return
+	}
+
+	switch addr {
+	case VBK:
+		p.VRAMBank = int(b & 0x01)
+	case BCPS:
+		p.BCPS = b & 0xBF
+	case BCPD:
+		writePaletteData(&p.BGPalettes, &p.BCPS, b, p.VRAMAccessible())
+	case OCPS:
+		p.OCPS = b & 0xBF
+	case OCPD:
+		writePaletteData(&p.OBJPalettes, &p.OCPS, b, p.VRAMAccessible())
+	case OPRI:
+		p.OPRI = b & 0x01
+	}
+}
+
+// writePaletteData writes to palette RAM at the index given by the
+// specification register and increments it if requested, even when the
+// palette RAM is not accessible.
+func writePaletteData(ram *[64]byte, spec *byte, b byte, accessible bool) {
+	index := *spec & 0x3F
+	if accessible {
+		ram[index] = b
+	}
+
+	if *spec&0x80 == 0x80 {
+		*spec = 0x80 | ((index + 1) & 0x3F)
+	}
+}
+
+// SetCGB enables CGB hardware emulation, mode tells if CGB features are
+// enabled or if we are running a DMG game in compatibility mode.
+func (p *PPU) SetCGB(hardware, mode bool) {
+	p.CGB = hardware
+	p.CGBMode = hardware && mode
+	if !p.CGBMode {
+		p.VRAMBank = 0
+	}
+}
+
+// SetPalette writes the four RGB555 colors of a palette in palette RAM.
+func (p *PPU) SetPalette(obj bool, index int, colors [4]uint16) {
+	ram := &p.BGPalettes
+	if obj {
+		ram = &p.OBJPalettes
+	}
+
+	for i, v := range colors {
+		ram[index*8+i*2] = byte(v & 0x00FF)
+		ram[index*8+i*2+1] = byte(v >> 8)
+	}
+}
+
+// SetCompatPalettes sets the palettes used to colorize a DMG game on CGB
+// hardware, the DMG palette registers select colors from these.
+func (p *PPU) SetCompatPalettes(palettes CompatPalettes) {
+	p.SetPalette(false, 0, palettes.BG)
+	p.SetPalette(true, 0, palettes.OBJ0)
+	p.SetPalette(true, 1, palettes.OBJ1)
+}
+
+// paletteColor returns the RGB color of a palette RAM entry.
+func paletteColor(ram *[64]byte, palette, index byte) color.RGBA {
+	i := int(palette&0x07)*8 + int(index)*2
+	return RGB555ToRGBA(uint16(ram[i]) | uint16(ram[i+1])<<8)
+}
+
+// RGB555ToRGBA converts a CGB color to RGBA.
+func RGB555ToRGBA(v uint16) color.RGBA {
+	scale := func(c uint16) uint8 {
+		c &= 0x1F
+		return uint8((c << 3) | (c >> 2))
+	}
+
+	return color.RGBA{scale(v), scale(v >> 5), scale(v >> 10), 255}
+}
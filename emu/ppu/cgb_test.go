@@ -0,0 +1,41 @@
+package ppu
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPaletteAutoIncrement(t *testing.T) {
+	p := New(make(chan *image.RGBA, 1))
+	p.SetCGB(true, true)
+
+	p.WriteRegister(BCPS, 0x80|0x3E)
+	for _, v := range []byte{0x11, 0x22, 0x33} {
+		p.WriteRegister(BCPD, v)
+	}
+	if p.BGPalettes[0x3E] != 0x11 || p.BGPalettes[0x3F] != 0x22 || p.BGPalettes[0x00] != 0x33 {
+		t.Errorf("expected writes to wrap around palette RAM, got % X", p.BGPalettes)
+	}
+	if actual := p.FetchRegister(BCPS); actual != 0xC1 {
+		t.Errorf("expected BCPS to read C1, got %02X", actual)
+	}
+
+	p.WriteRegister(OCPS, 0x05)
+	p.WriteRegister(OCPD, 0x44)
+	p.WriteRegister(OCPD, 0x55)
+	if p.OBJPalettes[0x05] != 0x55 || p.FetchRegister(OCPS) != 0x45 {
+		t.Errorf("expected OCPS not to increment, got %02X", p.FetchRegister(OCPS))
+	}
+
+	// Writes are ignored during pixel transfer, the index still increments.
+	p.LCDC |= LCDCControl
+	p.Seek(0, 100)
+	p.WriteRegister(BCPS, 0x80|0x10)
+	p.WriteRegister(BCPD, 0x66)
+	if p.BGPalettes[0x10] != 0x00 || p.FetchRegister(BCPS) != 0xD1 {
+		t.Errorf("expected the write to be ignored and BCPS to increment, got %02X", p.FetchRegister(BCPS))
+	}
+	if actual := p.FetchRegister(BCPD); actual != 0xFF {
+		t.Errorf("expected palette RAM to read FF during transfer, got %02X", actual)
+	}
+}
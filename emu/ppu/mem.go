@@ -4,7 +4,7 @@ import "fmt"
 
 // Returns true if the address is in the range of the PPU registers
 func IsPPUIO(addr uint16) bool {
-	return addr >= 0xFF40 && addr <= 0xFF4B
+	return (addr >= 0xFF40 && addr <= 0xFF4B) || IsCGBPPUIO(addr)
 }
 
 // Returns true if the address is in the range of VRAM
@@ -17,7 +17,12 @@ func (p *PPU) FetchVRAM(addr uint16) byte {
 		panic(fmt.Errorf("PPU VRAM fetch outside of 0x800-0x9FFF: %02X", addr))
 	}
 
-	return p.VRAM[addr-0x8000]
+	return p.VRAM[p.VRAMBank*0x2000+int(addr-0x8000)]
+}
+
+// fetchVRAMBank reads VRAM from the given bank regardless of VBK.
+func (p *PPU) fetchVRAMBank(bank int, addr uint16) byte {
+	return p.VRAM[bank*0x2000+int(addr-0x8000)]
 }
 
 func (p *PPU) WriteVRAM(addr uint16, b byte) {
@@ -25,22 +30,72 @@ func (p *PPU) WriteVRAM(addr uint16, b byte) {
 		panic(fmt.Errorf("PPU VRAM write outside of 0x800-0x9FFF: %02X", addr))
 	}
 
-	p.VRAM[addr-0x8000] = b
+	p.VRAM[p.VRAMBank*0x2000+int(addr-0x8000)] = b
+}
+
+// Returns true if the address is in the range of OAM
+func IsOAM(addr uint16) bool {
+	return addr >= 0xFE00 && addr <= 0xFE9F
+}
+
+func (p *PPU) FetchOAM(addr uint16) byte {
+	if !IsOAM(addr) {
+		panic(fmt.Errorf("PPU OAM fetch outside of 0xFE00-0xFE9F: %02X", addr))
+	}
+
+	return p.OAM[addr-0xFE00]
 }
 
+func (p *PPU) WriteOAM(addr uint16, b byte) {
+	if !IsOAM(addr) {
+		panic(fmt.Errorf("PPU OAM write outside of 0xFE00-0xFE9F: %02X", addr))
+	}
+
+	p.OAM[addr-0xFE00] = b
+}
+
+// VRAMAccessible returns false when the PPU is reading VRAM and the CPU can't.
+func (p *PPU) VRAMAccessible() bool {
+	return !p.LCDCHas(LCDCControl) || p.GetMode() != ModeTransfer
+}
+
+// OAMAccessible returns false when the PPU is reading OAM and the CPU can't.
+func (p *PPU) OAMAccessible() bool {
+	mode := p.GetMode()
+	return !p.LCDCHas(LCDCControl) || mode == ModeHBlank || mode == ModeVBlank
+}
+
+// Fetch reads a register, VRAM, or OAM as the CPU would, reading VRAM or OAM
+// while the PPU uses them returns 0xFF.
 func (p *PPU) Fetch(addr uint16) byte {
-	if IsPPUIO(addr) {
+	switch {
+	case IsPPUIO(addr):
 		return p.FetchRegister(addr)
+	case IsOAM(addr):
+		if !p.OAMAccessible() {
+			return 0xFF
+		}
+		return p.FetchOAM(addr)
+	}
+
+	if !p.VRAMAccessible() {
+		return 0xFF
 	}
 
 	return p.FetchVRAM(addr)
 }
 
+// Write writes a register, VRAM, or OAM as the CPU would, writing VRAM or
+// OAM while the PPU uses them has no effect.
 func (p *PPU) Write(addr uint16, b byte) {
-	if IsPPUIO(addr) {
+	switch {
+	case IsPPUIO(addr):
 		p.WriteRegister(addr, b)
-		return
+	case IsOAM(addr):
+		if p.OAMAccessible() {
+			p.WriteOAM(addr, b)
+		}
+	case p.VRAMAccessible():
+		p.WriteVRAM(addr, b)
 	}
-
-	p.WriteVRAM(addr, b)
 }
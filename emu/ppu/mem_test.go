@@ -0,0 +1,50 @@
+package ppu
+
+import (
+	"image"
+	"testing"
+)
+
+func TestAccessible(t *testing.T) {
+	cases := []struct {
+		lcdOn         bool
+		line, dot     int
+		vramOK, oamOK bool
+	}{
+		{false, 0, 100, true, true},
+		{true, 0, 0, true, false},    // OAM scan
+		{true, 0, 100, false, false}, // transfer
+		{true, 0, 300, true, true},   // HBlank
+		{true, 150, 0, true, true},   // VBlank
+	}
+
+	for _, v := range cases {
+		p := New(make(chan *image.RGBA, 1))
+		if v.lcdOn {
+			p.LCDC |= LCDCControl
+		}
+		p.Seek(v.line, v.dot)
+
+		if actual := p.VRAMAccessible(); actual != v.vramOK {
+			t.Errorf("%d:%d: expected VRAMAccessible %t, got %t", v.line, v.dot, v.vramOK, actual)
+		}
+		if actual := p.OAMAccessible(); actual != v.oamOK {
+			t.Errorf("%d:%d: expected OAMAccessible %t, got %t", v.line, v.dot, v.oamOK, actual)
+		}
+
+		p.VRAM[0], p.OAM[0] = 0x12, 0x34
+		expectedVRAM, expectedOAM := byte(0xFF), byte(0xFF)
+		if v.vramOK {
+			expectedVRAM = 0x12
+		}
+		if v.oamOK {
+			expectedOAM = 0x34
+		}
+		if actual := p.Fetch(0x8000); actual != expectedVRAM {
+			t.Errorf("%d:%d: expected VRAM to read %02X, got %02X", v.line, v.dot, expectedVRAM, actual)
+		}
+		if actual := p.Fetch(0xFE00); actual != expectedOAM {
+			t.Errorf("%d:%d: expected OAM to read %02X, got %02X", v.line, v.dot, expectedOAM, actual)
+		}
+	}
+}
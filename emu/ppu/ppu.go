@@ -1,7 +1,6 @@
 package ppu
 
 import (
-	"fmt"
 	"image"
 	"image/color"
 )
@@ -12,17 +11,49 @@ const (
 )
 
 type PPU struct {
-	VRAM [8192]byte
+	// VRAM holds both VRAM banks, the second one is only used in CGB mode
+	VRAM [2 * 8192]byte
+
+	// OAM holds the attributes of the 40 sprites, mapped to FE00-FE9F
+	OAM [0xA0]byte
+
+	// CGB is set when emulating Game Boy Color hardware, colors then always
+	// come from the palette RAM.
+	CGB bool
+
+	// CGBMode is set when CGB features are enabled, it is unset when running
+	// a DMG game on CGB hardware (compatibility mode).
+	CGBMode bool
 
 	// CPU Cycles since last full display
 	Cycles int
 
-	// True right _after_ the cycle that got to VBlank ran
+	// Set when the cycle that got to VBlank ran, the CPU resets it when it
+	// requests the interrupt.
 	InterruptVBlank bool
 
+	// Set when a visible line entered HBlank, the CPU resets it when it ran
+	// the HBlank DMA.
+	HBlankStarted bool
+
+	// Set once LY reads 0 on line 153.
+	ly153 bool
+
+	// Line of the window to draw next, the window keeps its own line counter
+	// that only increments when the window was drawn.
+	windowLine int
+
 	Buffers         [2]*image.RGBA
 	BackBufferIndex int
 
+	// Shades holds the DMG shade (0-3) of every pixel of the frame being
+	// drawn, as sent to the LCD before coloring.
+	Shades [DotMatrixWidth * DotMatrixHeight]byte
+
+	// Colorizer, when set, replaces the frames sent to the renderer.
+	Colorizer Colorizer
+	colorized *image.RGBA
+
 	// We'll send to this when we're ready to display a frame
 	NextFrame    chan<- *image.RGBA
 	PushedFrames int
@@ -40,6 +71,14 @@ type PPU struct {
 	OBP1 byte
 	WY   byte
 	WX   byte
+
+	// CGB registers mapped to FF4F and FF68-FF6C
+	VRAMBank    int
+	BCPS        byte
+	OCPS        byte
+	OPRI        byte
+	BGPalettes  [64]byte
+	OBJPalettes [64]byte
 }
 
 func New(nextFrame chan<- *image.RGBA) *PPU {
@@ -57,26 +96,36 @@ func New(nextFrame chan<- *image.RGBA) *PPU {
 
 // Runs the PPU for one cycle
 func (p *PPU) Cycle() {
-	p.Draw()
+	line := p.line()
 	p.Cycles = (p.Cycles + 1) % 456
-	p.InterruptVBlank = false
 
 	if p.Cycles == 0 {
-		p.LY = (p.LY + 1) % 154
+		line = (line + 1) % 154
+		p.LY = byte(line)
+		p.ly153 = false
+		if line == 0 {
+			p.windowLine = 0
+		}
 	}
-	p.SetSTATLYC(false)
 
-	if p.LY >= 0 && p.LY <= 143 {
-		p.SetSTATMode(ModeHBlank)
+	if line >= 0 && line <= 143 {
+		switch {
+		case p.Cycles < 80:
+			p.SetSTATMode(ModeOAM)
+		case p.Cycles < 252:
+			p.SetSTATMode(ModeTransfer)
+		default:
+			if p.GetMode() == ModeTransfer {
+				p.renderLine()
+				p.HBlankStarted = true
+			}
+			p.SetSTATMode(ModeHBlank)
+		}
 
 		if p.Cycles == 4 {
 			p.SetSTATLYC(p.LY == p.LYC)
-		} else if p.Cycles >= 80 && p.Cycles <= 84 {
-			p.SetSTATMode(ModeOAM)
-		} else if p.Cycles > 84 && p.Cycles < 448 {
-			p.SetSTATMode(ModeHBlank)
 		}
-	} else if p.LY == 144 {
+	} else if line == 144 {
 		p.SetSTATMode(ModeHBlank)
 
 		if p.Cycles >= 4 {
@@ -88,28 +137,82 @@ func (p *PPU) Cycle() {
 
 			p.SetSTATMode(ModeVBlank)
 		}
-	} else if p.LY >= 144 && p.LY < 153 {
+	} else if line >= 144 && line < 153 {
 		p.SetSTATMode(ModeVBlank)
 		if p.Cycles == 4 {
 			p.SetSTATLYC(p.LY == p.LYC)
 		}
-	} else if p.LY == 153 {
+	} else if line == 153 {
 		p.SetSTATMode(ModeVBlank)
+
+		// LY already reads 0 for most of the last line.
+		if p.Cycles == 4 {
+			p.LY = 0
+			p.ly153 = true
+		}
+
 		if p.Cycles == 4 || p.Cycles == 12 {
 			p.SetSTATLYC(p.LY == p.LYC)
 		}
 	}
 }
 
+// line returns the line being drawn, LY differs on line 153.
+func (p *PPU) line() int {
+	if p.ly153 {
+		return 153
+	}
+
+	return int(p.LY)
+}
+
+// Seek puts the PPU at the given line and dot, setting LY and STAT as they
+// would read at that point.
+func (p *PPU) Seek(line, dot int) {
+	p.LY = byte(line)
+	p.ly153 = false
+	p.Cycles = dot
+
+	switch {
+	case line >= 144:
+		p.SetSTATMode(ModeVBlank)
+		if line == 153 && dot >= 4 {
+			p.LY = 0
+			p.ly153 = true
+		}
+	case dot < 80:
+		p.SetSTATMode(ModeOAM)
+	case dot < 252:
+		p.SetSTATMode(ModeTransfer)
+	default:
+		p.SetSTATMode(ModeHBlank)
+	}
+
+	p.SetSTATLYC(p.LY == p.LYC)
+}
+
+// Colorizer creates the displayed frame from the DMG shades, it is used to
+// emulate the Super Game Boy.
+type Colorizer interface {
+	// Colorize is called once per frame and returns the image to display.
+	Colorize(shades []byte) *image.RGBA
+}
+
 // SendFrame sends a frame to the renderer
 func (p *PPU) SendFrame() {
+	frame := p.Buffers[p.BackBufferIndex]
+	if p.Colorizer != nil {
+		p.colorized = p.Colorizer.Colorize(p.Shades[:])
+		frame = p.colorized
+	}
+
 	// Send the current back buffer and promote it to front
 	// HACK: This call is blocking, thus ensuring we don't run the emulation
 	// crazy fast, this only works if the receiver runs at 60hz of course
 	// ie. my monitor and graphics card run at 60hz with vsync enabled so
 	// this line is the actual simulation speed regulator
 	if p.NextFrame != nil { // nil in tests because we don't care about pictures
-		p.NextFrame <- p.Buffers[p.BackBufferIndex]
+		p.NextFrame <- frame
 	}
 
 	p.PushedFrames++
@@ -120,29 +223,13 @@ func (p *PPU) BackBuffer() *image.RGBA {
 	return p.Buffers[p.BackBufferIndex]
 }
 
-func (p *PPU) Draw() {
-	lcdX := byte(p.Cycles / 2)
-	lcdY := p.LY
-	if lcdY >= DotMatrixHeight || lcdX >= DotMatrixWidth {
-		return
-	}
-
-	x := (lcdX + p.SCX) % DotMatrixWidth
-	y := (lcdY + p.SCY) % DotMatrixHeight
-	p.BackBuffer().SetRGBA(int(x), int(y), Colorize(0))
-
-	if p.LCDCHas(LCDCDisplayBGAndWindow) {
-		p.DrawBackground(x, y)
+// FrontBuffer returns the last complete frame.
+func (p *PPU) FrontBuffer() *image.RGBA {
+	if p.colorized != nil {
+		return p.colorized
 	}
-}
-
-func (p *PPU) DrawBackground(x, y byte) {
-	dataAddr := p.GetTileDataAddress(x, y)
-	tileData := p.GetTileData(dataAddr, x, y)
 
-	c := Colorize(p.Palettize(tileData))
-
-	p.BackBuffer().SetRGBA(int(x), int(y), c)
+	return p.Buffers[(p.BackBufferIndex+1)%2]
 }
 
 func Colorize(b byte) color.RGBA {
@@ -161,62 +248,14 @@ func Colorize(b byte) color.RGBA {
 }
 
 func (p *PPU) Palettize(b byte) byte {
-	switch b {
-	case 0x00:
-		return p.BGP & 0x03
-	case 0x01:
-		return (p.BGP & 0x0C) >> 2
-	case 0x02:
-		return (p.BGP & 0x30) >> 4
-	case 0x03:
-		return (p.BGP & 0xC0) >> 6
-	}
-
-	panic("trying to palette byte > 3")
-}
-
-// GetTileMapID returns the tile ID of the tile that should be displayed at pixel x, y
-func (p *PPU) GetTileMapID(x, y byte) byte {
-	mapOffset, end := p.GetBGTileMapRange()
-	tileX, tileY := uint16(x/8), uint16(y/8)
-
-	tileIDAddr := mapOffset + tileX + (tileY * 32)
-	if tileIDAddr > end {
-		panic("fetching tile outside of tile map")
-	}
-
-	return p.FetchVRAM(tileIDAddr)
+	return palettize(p.BGP, b)
 }
 
-// Returns the adress of the tile data for pixel at x,y
-func (p *PPU) GetTileDataAddress(x, y byte) uint16 {
-	tileID := p.GetTileMapID(x, y)
-
-	dataOffset, end := p.GetBGWindowTileDataRange()
-	addr := dataOffset
-	if p.LCDCHas(LCDCBGWindowTileDataSelect) {
-		addr += uint16(tileID) * 16
-	} else {
-		addr += uint16(int16(tileID) * 16)
+// palettize returns the shade a DMG palette register gives to a color index.
+func palettize(palette, b byte) byte {
+	if b > 0x03 {
+		panic("trying to palette byte > 3")
 	}
 
-	if addr > end {
-		panic(fmt.Errorf("%04X > %04X for tile #%02X", addr, end, tileID))
-	}
-
-	return addr
-}
-
-// Returns the tile data for pixel x,y of tile at address addr
-func (p *PPU) GetTileData(addr uint16, x, y byte) byte {
-	dX := byte(7 - (x % 8))   // one bit per pixel half-color, MSB first
-	dY := uint16(2 * (y % 8)) // two bytes per row
-
-	a := p.FetchVRAM(addr + dY)
-	b := p.FetchVRAM(addr + dY + 1)
-
-	bit1 := (a & (1 << dX)) >> dX
-	bit2 := (b & (1 << dX)) >> dX
-
-	return bit1 | (bit2 << 1)
+	return (palette >> (b * 2)) & 0x03
 }
@@ -4,6 +4,10 @@ import "fmt"
 
 // Reads a byte from the registers
 func (p *PPU) FetchRegister(addr uint16) byte {
+	if IsCGBPPUIO(addr) {
+		return p.fetchCGBRegister(addr)
+	}
+
 	switch addr {
 	case 0xFF40:
 		return p.LCDC
@@ -32,20 +36,25 @@ func (p *PPU) FetchRegister(addr uint16) byte {
 	}
 
 	// This is not an emulation problem, somone dun' goofed.
-	panic(fmt.Errorf("PPU register fetch outside of 0xFF40-0xFF4B: %02X", addr))
+	panic(fmt.Errorf("PPU register fetch outside of PPU registers: %02X", addr))
 }
 
 func (p *PPU) WriteRegister(addr uint16, b byte) {
 	if !IsPPUIO(addr) {
 		// This is not an emulation problem, somone dun' goofed.
-		panic(fmt.Errorf("PPU register write outside of 0xFF40-0xFF4B: %02X", addr))
+		panic(fmt.Errorf("PPU register write outside of PPU registers: %02X", addr))
+	}
+
+	if IsCGBPPUIO(addr) {
+		p.writeCGBRegister(addr, b)
+		return
 	}
 
 	switch addr {
 	case 0xFF40:
 		p.LCDC = b
 	case 0xFF41:
-		p.STAT = (b & 0x78) | p.STAT // mask 0b01111000
+		p.STAT = 0x80 | (b & 0x78) | (p.STAT & 0x07) // only bits 3-6 are writable
 	case 0xFF42:
 		p.SCY = b
 	case 0xFF43:
@@ -85,10 +94,11 @@ func (p *PPU) SetSTATMode(mode byte) {
 	p.STAT = (p.STAT & 0xFC) | mode
 }
 
+// SetSTATLYC sets the LY=LYC coincidence flag.
 func (p *PPU) SetSTATLYC(on bool) {
 	if on {
-		p.STAT |= 1 << 6
+		p.STAT |= 1 << 2
 	} else {
-		p.STAT &= ^uint8(1 << 6)
+		p.STAT &= ^uint8(1 << 2)
 	}
 }
@@ -0,0 +1,254 @@
+package ppu
+
+import (
+	"image/color"
+	"sort"
+)
+
+// OAM sprite attributes flags, the palette and bank are only used in CGB mode.
+const (
+	SpriteAttrCGBPalette  = 0x07
+	SpriteAttrBank        = 1 << 3
+	SpriteAttrDMGPalette  = 1 << 4
+	SpriteAttrXFlip       = 1 << 5
+	SpriteAttrYFlip       = 1 << 6
+	SpriteAttrBGPriority  = 1 << 7
+	maxSpritesPerLine     = 10
+	spriteAttributesBytes = 4
+)
+
+type sprite struct {
+	x, y int
+	tile byte
+	attr byte
+}
+
+// renderLine draws the current line to the back buffer.
+func (p *PPU) renderLine() {
+	y := int(p.LY)
+	buf := p.BackBuffer()
+
+	if !p.LCDCHas(LCDCControl) {
+		for x := 0; x < DotMatrixWidth; x++ {
+			buf.SetRGBA(x, y, p.bgColor(0, 0))
+			p.Shades[y*DotMatrixWidth+x] = 0
+		}
+		return
+	}
+
+	// Color index (before palette) and attributes of the BG/window pixels,
+	// needed to know if a sprite should be drawn above them.
+	var bgIndex [DotMatrixWidth]byte
+	var bgAttr [DotMatrixWidth]byte
+
+	p.renderBackground(y, &bgIndex, &bgAttr)
+	for x := 0; x < DotMatrixWidth; x++ {
+		buf.SetRGBA(x, y, p.bgColor(bgIndex[x], bgAttr[x]))
+		p.Shades[y*DotMatrixWidth+x] = palettize(p.BGP, bgIndex[x])
+	}
+
+	if p.LCDCHas(LCDCDisplaySprite) {
+		p.renderSprites(y, &bgIndex, &bgAttr)
+	}
+}
+
+// renderBackground fetches the color index and attributes of the BG and
+// window pixels of a line.
+func (p *PPU) renderBackground(y int, bgIndex, bgAttr *[DotMatrixWidth]byte) {
+	// On DMG this bit disables both BG and window, in CGB mode it only takes
+	// priority away from them.
+	if !p.CGBMode && !p.LCDCHas(LCDCDisplayBGAndWindow) {
+		return
+	}
+
+	bgMap, _ := p.GetBGTileMapRange()
+	windowMap, _ := p.GetWindowTileMapRange()
+	windowX := int(p.WX) - 7
+	drawWindow := p.LCDCHas(LCDCBGWindowDisplay) && int(p.WY) <= y && p.WX <= 166
+
+	for x := 0; x < DotMatrixWidth; x++ {
+		mapAddr := bgMap
+		mapX := (x + int(p.SCX)) & 0xFF
+		mapY := (y + int(p.SCY)) & 0xFF
+
+		if drawWindow && x >= windowX {
+			mapAddr = windowMap
+			mapX = x - windowX
+			mapY = p.windowLine
+		}
+
+		mapAddr += uint16((mapY/8)*32 + mapX/8)
+		tileID := p.fetchVRAMBank(0, mapAddr)
+
+		var attr byte
+		if p.CGBMode {
+			attr = p.fetchVRAMBank(1, mapAddr)
+		}
+
+		bgIndex[x] = p.tilePixel(p.bgTileAddress(tileID), attr, mapX%8, mapY%8, 8)
+		bgAttr[x] = attr
+	}
+
+	if drawWindow && windowX < DotMatrixWidth {
+		p.windowLine++
+	}
+}
+
+// bgTileAddress returns the address of the BG/window tile data of a tile ID.
+func (p *PPU) bgTileAddress(tileID byte) uint16 {
+	if p.LCDCHas(LCDCBGWindowTileDataSelect) {
+		return 0x8000 + uint16(tileID)*16
+	}
+
+	return uint16(0x9000 + int(int8(tileID))*16)
+}
+
+// tilePixel returns the color index of a pixel in a tile, attr gives the
+// VRAM bank and flips.
+func (p *PPU) tilePixel(addr uint16, attr byte, x, y, height int) byte {
+	if attr&AttrXFlip == AttrXFlip {
+		x = 7 - x
+	}
+	if attr&AttrYFlip == AttrYFlip {
+		y = height - 1 - y
+	}
+
+	bank := 0
+	if attr&AttrBank == AttrBank {
+		bank = 1
+	}
+
+	rowAddr := addr + uint16(y*2) // two bytes per row
+	a := p.fetchVRAMBank(bank, rowAddr)
+	b := p.fetchVRAMBank(bank, rowAddr+1)
+	bit := uint(7 - x) // one bit per pixel half-color, MSB first
+
+	return ((a >> bit) & 1) | (((b >> bit) & 1) << 1)
+}
+
+// renderSprites draws the sprites of a line above the background.
+func (p *PPU) renderSprites(y int, bgIndex, bgAttr *[DotMatrixWidth]byte) {
+	_, height := p.GetSpriteSize()
+	sprites := p.lineSprites(y, int(height))
+	buf := p.BackBuffer()
+
+	// Draw from the lowest priority to the highest, only opaque pixels
+	// overwrite the previous ones.
+	var index [DotMatrixWidth]byte
+	var owner [DotMatrixWidth]*sprite
+	for i := len(sprites) - 1; i >= 0; i-- {
+		s := &sprites[i]
+		tile := s.tile
+		if height == 16 {
+			tile &= 0xFE
+		}
+		addr := 0x8000 + uint16(tile)*16
+
+		attr := s.attr &^ SpriteAttrBank
+		if p.CGBMode {
+			attr = s.attr
+		}
+
+		for px := 0; px < 8; px++ {
+			x := s.x + px
+			if x < 0 || x >= DotMatrixWidth {
+				continue
+			}
+
+			if v := p.tilePixel(addr, attr, px, y-s.y, int(height)); v != 0 {
+				index[x] = v
+				owner[x] = s
+			}
+		}
+	}
+
+	for x, s := range owner {
+		if s == nil || !p.spriteVisible(s.attr, bgIndex[x], bgAttr[x]) {
+			continue
+		}
+
+		buf.SetRGBA(x, y, p.spriteColor(index[x], s.attr))
+		palette, _ := p.spritePalette(s.attr)
+		p.Shades[y*DotMatrixWidth+x] = palettize(palette, index[x])
+	}
+}
+
+// lineSprites returns the sprites displayed on a line, sorted by priority.
+func (p *PPU) lineSprites(y, height int) []sprite {
+	sprites := make([]sprite, 0, maxSpritesPerLine)
+	for i := 0; i < len(p.OAM) && len(sprites) < maxSpritesPerLine; i += spriteAttributesBytes {
+		s := sprite{
+			y:    int(p.OAM[i]) - 16,
+			x:    int(p.OAM[i+1]) - 8,
+			tile: p.OAM[i+2],
+			attr: p.OAM[i+3],
+		}
+
+		if y >= s.y && y < s.y+height {
+			sprites = append(sprites, s)
+		}
+	}
+
+	// CGB uses the OAM order, DMG gives priority to the leftmost sprite.
+	if !p.CGBMode || p.OPRI&0x01 == 0x01 {
+		sort.SliceStable(sprites, func(i, j int) bool {
+			return sprites[i].x < sprites[j].x
+		})
+	}
+
+	return sprites
+}
+
+// spriteVisible returns true if a sprite pixel is drawn above the BG/window.
+func (p *PPU) spriteVisible(spriteAttr, bgIndex, bgAttr byte) bool {
+	if bgIndex == 0 {
+		return true
+	}
+
+	if p.CGBMode {
+		if !p.LCDCHas(LCDCDisplayBGAndWindow) {
+			return true
+		}
+		if bgAttr&AttrPriority == AttrPriority {
+			return false
+		}
+	}
+
+	return spriteAttr&SpriteAttrBGPriority == 0
+}
+
+// bgColor returns the color of a BG/window pixel.
+func (p *PPU) bgColor(index, attr byte) color.RGBA {
+	switch {
+	case p.CGBMode:
+		return paletteColor(&p.BGPalettes, attr&AttrPalette, index)
+	case p.CGB:
+		return paletteColor(&p.BGPalettes, 0, palettize(p.BGP, index))
+	}
+
+	return Colorize(palettize(p.BGP, index))
+}
+
+// spriteColor returns the color of a sprite pixel.
+func (p *PPU) spriteColor(index, attr byte) color.RGBA {
+	if p.CGBMode {
+		return paletteColor(&p.OBJPalettes, attr&SpriteAttrCGBPalette, index)
+	}
+
+	palette, paletteIndex := p.spritePalette(attr)
+	if p.CGB {
+		return paletteColor(&p.OBJPalettes, paletteIndex, palettize(palette, index))
+	}
+
+	return Colorize(palettize(palette, index))
+}
+
+// spritePalette returns the DMG palette register used by a sprite and its
+// index (OBP0 or OBP1).
+func (p *PPU) spritePalette(attr byte) (byte, byte) {
+	if attr&SpriteAttrDMGPalette == SpriteAttrDMGPalette {
+		return p.OBP1, 1
+	}
+
+	return p.OBP0, 0
+}
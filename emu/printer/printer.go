@@ -0,0 +1,337 @@
+// Package printer emulates a Game Boy Printer plugged in the link port, the
+// printed strips are saved as PNG files.
+package printer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/L-P/poussin/emu/cpu"
+)
+
+// Packet commands
+const (
+	CommandInit   = 0x01
+	CommandPrint  = 0x02
+	CommandData   = 0x04
+	CommandStatus = 0x0F
+)
+
+// Status flags
+const (
+	StatusChecksumError   = 1 << 0
+	StatusPrinting        = 1 << 1
+	StatusImageDataFull   = 1 << 2
+	StatusUnprocessedData = 1 << 3
+	StatusPacketError     = 1 << 4
+	StatusPaperJam        = 1 << 5
+	StatusOtherError      = 1 << 6
+	StatusLowBattery      = 1 << 7
+)
+
+const (
+	// Printed width in pixels, a DATA packet holds two rows of 20 tiles.
+	Width = 160
+
+	// bandSize is the size in bytes of two rows of 20 tiles.
+	bandSize = 20 * 16 * 2
+
+	// The printer RAM can hold 9 bands (a full screen).
+	bufferSize = 9 * bandSize
+
+	// Height in pixels of a margin line feed.
+	marginLineHeight = 16
+
+	// Number of cycles the printer stays busy after a PRINT command.
+	printCycles = 4194304 / 4
+
+	// The printer replies with its ID after the checksum.
+	deviceID = 0x81
+)
+
+// Packet parsing states, in reception order.
+const (
+	stateMagic1 = iota
+	stateMagic2
+	stateCommand
+	stateCompression
+	stateLengthLow
+	stateLengthHigh
+	stateData
+	stateChecksumLow
+	stateChecksumHigh
+	stateDeviceID
+	stateStatus
+)
+
+// Printer is a cpu.SerialPeer emulating the Game Boy Printer.
+type Printer struct {
+	// Dir is where the PNG files are written.
+	Dir string
+
+	state       int
+	command     byte
+	compressed  bool
+	length      uint16
+	data        []byte
+	checksum    uint16
+	sum         uint16
+	status      byte
+	busyCycles  int
+	buffer      []byte
+	strip       []uint8 // shades of the strip being printed, Width per line
+	printCount  int
+	lastSaveErr error
+}
+
+// New creates a Printer saving its prints in the given directory.
+func New(dir string) *Printer {
+	return &Printer{Dir: dir}
+}
+
+// Close saves any strip that was printed without a margin after it.
+func (p *Printer) Close() error {
+	p.saveStrip()
+	return p.lastSaveErr
+}
+
+// Transfer receives a byte from the Game Boy and returns the byte the
+// printer shifted out at the same time.
+func (p *Printer) Transfer(out byte) byte {
+	in := byte(0x00)
+
+	switch p.state {
+	case stateMagic1:
+		if out == 0x88 {
+			p.state = stateMagic2
+		}
+		return in
+	case stateMagic2:
+		p.state = stateCommand
+		if out != 0x33 {
+			p.state = stateMagic1
+		}
+		return in
+	case stateCommand:
+		p.command = out
+		p.sum = uint16(out)
+	case stateCompression:
+		p.compressed = out&0x01 == 0x01
+		p.sum += uint16(out)
+	case stateLengthLow:
+		p.length = uint16(out)
+		p.sum += uint16(out)
+	case stateLengthHigh:
+		p.length |= uint16(out) << 8
+		p.sum += uint16(out)
+		p.data = p.data[:0]
+		if p.length == 0 {
+			p.state++ // skip data
+		}
+	case stateData:
+		p.data = append(p.data, out)
+		p.sum += uint16(out)
+		if len(p.data) < int(p.length) {
+			return in
+		}
+	case stateChecksumLow:
+		p.checksum = uint16(out)
+	case stateChecksumHigh:
+		p.checksum |= uint16(out) << 8
+		p.runCommand()
+	case stateDeviceID:
+		in = deviceID
+	case stateStatus:
+		in = p.status
+		p.state = stateMagic1
+		return in
+	}
+
+	p.state++
+
+	return in
+}
+
+// Sync counts down the time spent printing.
+func (p *Printer) Sync(s *cpu.Serial, cycles int) {
+	if p.busyCycles <= 0 {
+		return
+	}
+
+	p.busyCycles -= cycles
+	if p.busyCycles <= 0 {
+		p.status &^= StatusPrinting
+	}
+}
+
+func (p *Printer) runCommand() {
+	if p.checksum != p.sum {
+		p.status |= StatusChecksumError
+		return
+	}
+	p.status &^= StatusChecksumError
+
+	switch p.command {
+	case CommandInit:
+		p.buffer = p.buffer[:0]
+		p.status &^= StatusUnprocessedData | StatusImageDataFull
+	case CommandData:
+		data := p.data
+		if p.compressed {
+			data = decompress(data)
+		}
+		p.buffer = append(p.buffer, data...)
+		if len(p.buffer) > bufferSize {
+			p.buffer = p.buffer[:bufferSize]
+		}
+		if len(p.buffer) > 0 {
+			p.status |= StatusUnprocessedData
+		}
+		if len(p.buffer) == bufferSize {
+			p.status |= StatusImageDataFull
+		}
+	case CommandPrint:
+		if len(p.data) < 4 {
+			p.status |= StatusPacketError
+			return
+		}
+		p.print(p.data[0], p.data[1], p.data[2])
+		p.buffer = p.buffer[:0]
+		p.status &^= StatusUnprocessedData | StatusImageDataFull
+		p.status |= StatusPrinting
+		p.busyCycles = printCycles
+	case CommandStatus:
+	default:
+		p.status |= StatusPacketError
+	}
+}
+
+// print adds the buffered image to the current strip, the strip is saved once
+// the paper is fed after printing.
+func (p *Printer) print(sheets, margins, palette byte) {
+	before := int(margins>>4) * marginLineHeight
+	after := int(margins&0x0F) * marginLineHeight
+
+	// Zero is sent by some games and treated as the usual palette.
+	if palette == 0x00 {
+		palette = 0xE4
+	}
+
+	p.feed(before)
+	if sheets > 0 {
+		p.strip = append(p.strip, bufferShades(p.buffer, palette)...)
+	}
+	p.feed(after)
+
+	if after > 0 {
+		p.saveStrip()
+	}
+}
+
+// feed adds blank lines to the strip.
+func (p *Printer) feed(lines int) {
+	if len(p.strip) == 0 {
+		return // nothing to separate yet
+	}
+
+	p.strip = append(p.strip, make([]uint8, lines*Width)...)
+}
+
+func (p *Printer) saveStrip() {
+	if len(p.strip) == 0 {
+		return
+	}
+
+	img := image.NewGray(image.Rect(0, 0, Width, len(p.strip)/Width))
+	for i, v := range p.strip {
+		img.Pix[i] = shadeToGray(v)
+	}
+	p.strip = p.strip[:0]
+
+	p.printCount++
+	name := fmt.Sprintf(
+		"print-%s-%d.png",
+		time.Now().Format("20060102-150405"),
+		p.printCount,
+	)
+
+	if err := savePNG(filepath.Join(p.Dir, name), img); err != nil {
+		p.lastSaveErr = err
+	}
+}
+
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// bufferShades converts bands of 2bpp tiles to one shade per pixel.
+func bufferShades(buf []byte, palette byte) []uint8 {
+	bands := len(buf) / bandSize
+	shades := make([]uint8, bands*16*Width)
+
+	for i := 0; i < bands*40; i++ {
+		tile := buf[i*16 : (i+1)*16]
+		tileX, tileY := i%20, i/20
+
+		for y := 0; y < 8; y++ {
+			a, b := tile[y*2], tile[y*2+1]
+			for x := 0; x < 8; x++ {
+				bit := uint(7 - x)
+				index := ((a >> bit) & 1) | (((b >> bit) & 1) << 1)
+				shade := (palette >> (index * 2)) & 0x03
+				shades[(tileY*8+y)*Width+tileX*8+x] = shade
+			}
+		}
+	}
+
+	return shades
+}
+
+func shadeToGray(shade uint8) uint8 {
+	return color.Gray{Y: 0xFF - shade*0x55}.Y
+}
+
+// decompress expands the run-length encoding used by DATA packets.
+func decompress(data []byte) []byte {
+	var out []byte
+
+	for i := 0; i < len(data); {
+		b := data[i]
+		i++
+
+		if b&0x80 == 0x80 {
+			if i >= len(data) {
+				break
+			}
+			for n := 0; n < int(b&0x7F)+2; n++ {
+				out = append(out, data[i])
+			}
+			i++
+			continue
+		}
+
+		n := int(b) + 1
+		if i+n > len(data) {
+			n = len(data) - i
+		}
+		out = append(out, data[i:i+n]...)
+		i += n
+	}
+
+	return out
+}
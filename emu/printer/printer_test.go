@@ -0,0 +1,142 @@
+package printer
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// packet returns the bytes sent by the Game Boy for a command, including the
+// two trailing bytes clocking the device ID and status out.
+func packet(command byte, compressed bool, data []byte) []byte {
+	var compression byte
+	if compressed {
+		compression = 1
+	}
+
+	body := []byte{command, compression, byte(len(data)), byte(len(data) >> 8)}
+	body = append(body, data...)
+
+	var sum uint16
+	for _, v := range body {
+		sum += uint16(v)
+	}
+
+	out := append([]byte{0x88, 0x33}, body...)
+	return append(out, byte(sum), byte(sum>>8), 0x00, 0x00)
+}
+
+// send transfers a packet and returns the device ID and status replied.
+func send(p *Printer, packet []byte) (byte, byte) {
+	var replies []byte
+	for _, v := range packet {
+		replies = append(replies, p.Transfer(v))
+	}
+
+	return replies[len(replies)-2], replies[len(replies)-1]
+}
+
+func TestPrint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "printer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := New(dir)
+
+	// One band: a row of black tiles, then a light gray tile followed by
+	// white ones.
+	band := []byte{0xFE, 0xFF, 0xFE, 0xFF, 0xBE, 0xFF} // 320 × $FF
+	band = append(band, 0x0F)                          // 16 literal bytes
+	band = append(band, bytes.Repeat([]byte{0xFF, 0x00}, 8)...)
+	band = append(band, 0xFE, 0x00, 0xFE, 0x00, 0xAE, 0x00) // 304 × $00
+
+	steps := []struct {
+		name     string
+		packet   []byte
+		expected byte
+	}{
+		{"INIT", packet(CommandInit, false, nil), 0x00},
+		{"DATA", packet(CommandData, true, band), StatusUnprocessedData},
+		{"PRINT", packet(CommandPrint, false, []byte{1, 0x01, 0xE4, 0x40}), StatusPrinting},
+		{"INQUIRY", packet(CommandStatus, false, nil), StatusPrinting},
+	}
+	for _, v := range steps {
+		id, status := send(p, v.packet)
+		if id != deviceID || status != v.expected {
+			t.Fatalf("%s: expected %02X %02X, got %02X %02X", v.name, deviceID, v.expected, id, status)
+		}
+	}
+
+	p.Sync(nil, printCycles)
+	if _, status := send(p, packet(CommandStatus, false, nil)); status != 0x00 {
+		t.Errorf("expected printing to be done, got status %02X", status)
+	}
+
+	bad := packet(CommandInit, false, nil)
+	bad[6]++
+	if _, status := send(p, bad); status != StatusChecksumError {
+		t.Errorf("expected a checksum error, got status %02X", status)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "print-*.png"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("expected one print, got %v (%v)", paths, err)
+	}
+	img := decodePNG(t, paths[0])
+
+	// 16 printed lines followed by a 16 lines margin.
+	if actual := img.Bounds().Size(); actual != image.Pt(Width, 32) {
+		t.Fatalf("expected a %dx32 print, got %v", Width, actual)
+	}
+	pixels := []struct {
+		x, y     int
+		expected uint8
+	}{
+		{0, 0, 0x00},
+		{159, 7, 0x00},
+		{0, 8, 0xAA},
+		{7, 15, 0xAA},
+		{8, 8, 0xFF},
+		{0, 31, 0xFF},
+	}
+	for _, v := range pixels {
+		if actual := img.GrayAt(v.x, v.y).Y; actual != v.expected {
+			t.Errorf("(%d, %d): expected %02X, got %02X", v.x, v.y, v.expected, actual)
+		}
+	}
+}
+
+func decodePNG(t *testing.T, path string) *image.Gray {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("expected a grayscale image, got %T", img)
+	}
+
+	return gray
+}
+
+func TestDecompress(t *testing.T) {
+	data := []byte{0x81, 0xAA, 0x01, 0x12, 0x34, 0x80, 0x00}
+	expected := []byte{0xAA, 0xAA, 0xAA, 0x12, 0x34, 0x00, 0x00}
+	if actual := decompress(data); !bytes.Equal(actual, expected) {
+		t.Errorf("expected % X, got % X", expected, actual)
+	}
+}
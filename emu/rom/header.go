@@ -1,6 +1,9 @@
 package rom
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type Header struct {
 	Title         string
@@ -12,20 +15,87 @@ type Header struct {
 	RAMSize       byte
 	Japanese      bool
 	Version       byte
+	OldLicensee   byte
+	NewLicensee   string
+
+	// Manufacturer is the 4 letters code some CGB games have at the end of
+	// their title, empty when absent.
+	Manufacturer string
+
+	// TitleChecksum is the sum of the 16 title bytes, the CGB boot ROM uses
+	// it to pick a palette for DMG games.
+	TitleChecksum byte
+
+	// Decoded ROMSize and RAMSize, in bytes and banks (16 KiB for ROM,
+	// 8 KiB for RAM).
+	ROMBytes int
+	ROMBanks int
+	RAMBytes int
+	RAMBanks int
+
+	// Logo is the bitmap the boot ROM displays and compares to its own copy.
+	Logo      [HeaderLogoEnd - HeaderLogo]byte
+	LogoValid bool
+
+	// HeaderChecksum covers 0x134-0x14C, the boot ROM locks up if it does
+	// not match.
+	HeaderChecksum      byte
+	HeaderChecksumValid bool
+
+	// GlobalChecksum is the sum of all ROM bytes but its own, nothing
+	// checks it on real hardware.
+	GlobalChecksum      uint16
+	GlobalChecksumValid bool
 }
 
 const (
-	HeaderOldTitleStart = 0x0134
-	HeaderOldTitleEnd   = 0x0143
-	HeaderCGBFlag       = 0x0143
-	HeaderSGBFlag       = 0x0146
-	HeaderCartridgeType = 0x0147
-	HeaderROMSize       = 0x0148
-	HeaderRAMSize       = 0x0148
-	HeaderDestination   = 0x014A
-	HeaderVersion       = 0x014C
+	HeaderLogo            = 0x0104
+	HeaderLogoEnd         = 0x0134
+	HeaderOldTitleStart   = 0x0134
+	HeaderOldTitleEnd     = 0x0143
+	HeaderManufacturer    = 0x013F
+	HeaderCGBFlag         = 0x0143
+	HeaderNewLicensee     = 0x0144
+	HeaderSGBFlag         = 0x0146
+	HeaderCartridgeType   = 0x0147
+	HeaderROMSize         = 0x0148
+	HeaderRAMSize         = 0x0149
+	HeaderDestination     = 0x014A
+	HeaderOldLicensee     = 0x014B
+	HeaderVersion         = 0x014C
+	HeaderChecksum        = 0x014D
+	HeaderGlobalChecksum  = 0x014E
+	HeaderEnd             = 0x0150
+	oldLicenseeUseNewCode = 0x33
+	romBankSize           = 16 * 1024
+	ramBankSize           = 8 * 1024
 )
 
+// NintendoLogo is the logo every licensed cartridge has in its header.
+var NintendoLogo = [HeaderLogoEnd - HeaderLogo]byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83,
+	0x00, 0x0C, 0x00, 0x0D, 0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E,
+	0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99, 0xBB, 0xBB, 0x67, 0x63,
+	0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// Odd ROM sizes used by a few games, in 16 KiB banks.
+var oddROMSizes = map[byte]int{
+	0x52: 72,
+	0x53: 80,
+	0x54: 96,
+}
+
+// RAM sizes in bytes, 0x01 is unofficial and only used by homebrew.
+var ramSizes = map[byte]int{
+	0x00: 0,
+	0x01: 2 * 1024,
+	0x02: 8 * 1024,
+	0x03: 32 * 1024,
+	0x04: 128 * 1024,
+	0x05: 64 * 1024,
+}
+
 var CartridgeTypes = map[byte]string{
 	0x00: "ROM ONLY",
 	0x01: "MBC1",
@@ -57,8 +127,13 @@ var CartridgeTypes = map[byte]string{
 	0xFF: "HuC1+RAM+BATTERY",
 }
 
-func NewHeader(rom []byte) Header {
+// NewHeader parses the header of a ROM. On invalid sizes, the header is
+// returned along with the error.
+func NewHeader(rom []byte) (Header, error) {
 	h := Header{}
+	if len(rom) < HeaderEnd {
+		return h, fmt.Errorf("file too short to hold a ROM header: %d bytes", len(rom))
+	}
 
 	for i := HeaderOldTitleStart; i < HeaderOldTitleEnd; i++ {
 		if rom[i] == 0 {
@@ -70,6 +145,7 @@ func NewHeader(rom []byte) Header {
 	h.DMG = true
 	if rom[HeaderCGBFlag] == 0xC0 || rom[HeaderCGBFlag] == 0x80 {
 		h.DMG = false
+		h.Manufacturer = manufacturerCode(rom[HeaderManufacturer:HeaderCGBFlag])
 	}
 
 	h.CGBOnly = rom[HeaderCGBFlag] == 0xC0
@@ -78,8 +154,124 @@ func NewHeader(rom []byte) Header {
 	h.ROMSize = rom[HeaderROMSize]
 	h.RAMSize = rom[HeaderRAMSize]
 	h.Japanese = rom[HeaderDestination] == 0x00
+	h.OldLicensee = rom[HeaderOldLicensee]
+	h.NewLicensee = string(rom[HeaderNewLicensee : HeaderNewLicensee+2])
+	h.Version = rom[HeaderVersion]
+
+	for i := HeaderOldTitleStart; i <= HeaderOldTitleEnd; i++ {
+		h.TitleChecksum += rom[i]
+	}
+
+	copy(h.Logo[:], rom[HeaderLogo:HeaderLogoEnd])
+	h.LogoValid = h.Logo == NintendoLogo
+
+	h.HeaderChecksum = rom[HeaderChecksum]
+	h.HeaderChecksumValid = ComputeHeaderChecksum(rom) == h.HeaderChecksum
+
+	h.GlobalChecksum = uint16(rom[HeaderGlobalChecksum])<<8 | uint16(rom[HeaderGlobalChecksum+1])
+	h.GlobalChecksumValid = ComputeGlobalChecksum(rom) == h.GlobalChecksum
+
+	if err := h.decodeSizes(); err != nil {
+		return h, err
+	}
+
+	return h, nil
+}
+
+// decodeSizes decodes both size codes, the RAM size is decoded even if the
+// ROM size is invalid.
+func (h *Header) decodeSizes() error {
+	var err error
+	switch {
+	case h.ROMSize <= 0x08:
+		h.ROMBanks = 2 << h.ROMSize
+	case oddROMSizes[h.ROMSize] > 0:
+		h.ROMBanks = oddROMSizes[h.ROMSize]
+	default:
+		err = fmt.Errorf("invalid ROM size code: %02X", h.ROMSize)
+	}
+	h.ROMBytes = h.ROMBanks * romBankSize
+
+	size, ok := ramSizes[h.RAMSize]
+	if !ok {
+		if err == nil {
+			err = fmt.Errorf("invalid RAM size code: %02X", h.RAMSize)
+		}
+		return err
+	}
+	h.RAMBytes = size
+	h.RAMBanks = (size + ramBankSize - 1) / ramBankSize
+
+	return err
+}
+
+// manufacturerCode returns the code if it looks like one, CGB games without
+// a manufacturer code can have a longer title.
+func manufacturerCode(b []byte) string {
+	for _, v := range b {
+		if (v < 'A' || v > 'Z') && (v < '0' || v > '9') {
+			return ""
+		}
+	}
+
+	return string(b)
+}
+
+// Verify returns an error if the logo or header checksum are invalid, real
+// hardware would not boot the ROM. The global checksum is not checked.
+func (h *Header) Verify() error {
+	if !h.LogoValid {
+		return errors.New("invalid Nintendo logo")
+	}
+
+	if !h.HeaderChecksumValid {
+		return fmt.Errorf("invalid header checksum: %02X", h.HeaderChecksum)
+	}
+
+	return nil
+}
+
+// Licensee returns the name of the publisher.
+func (h *Header) Licensee() string {
+	if h.OldLicensee == oldLicenseeUseNewCode {
+		return NewLicensees[h.NewLicensee]
+	}
+
+	return OldLicensees[h.OldLicensee]
+}
+
+// ComputeHeaderChecksum returns the checksum of the header bytes 0x134-0x14C
+// that the boot ROM compares with the one stored at 0x14D.
+func ComputeHeaderChecksum(rom []byte) byte {
+	var x byte
+	for i := HeaderOldTitleStart; i < HeaderChecksum; i++ {
+		x = x - rom[i] - 1
+	}
+
+	return x
+}
+
+// ComputeGlobalChecksum returns the sum of all the ROM bytes except the two
+// checksum bytes.
+func ComputeGlobalChecksum(rom []byte) uint16 {
+	var sum uint16
+	for i, v := range rom {
+		if i != HeaderGlobalChecksum && i != HeaderGlobalChecksum+1 {
+			sum += uint16(v)
+		}
+	}
+
+	return sum
+}
+
+// IsNintendo returns true if Nintendo is the licensee, only those games get
+// a palette from the CGB boot ROM.
+func (h *Header) IsNintendo() bool {
+	if h.OldLicensee == oldLicenseeUseNewCode {
+		return h.NewLicensee == "01"
+	}
 
-	return h
+	return h.OldLicensee == 0x01
 }
 
 func (h *Header) String() string {
@@ -106,8 +298,8 @@ func (h *Header) String() string {
 	}
 
 	str += ", " + CartridgeTypes[h.CartridgeType]
-	str += fmt.Sprintf(", RAM: %02X", h.ROMSize)
-	str += fmt.Sprintf(", ROM: %02X", h.RAMSize)
+	str += fmt.Sprintf(", ROM: %d KiB", h.ROMBytes/1024)
+	str += fmt.Sprintf(", RAM: %d KiB", h.RAMBytes/1024)
 	str += fmt.Sprintf(", Version: %02X", h.Version)
 
 	return str
@@ -0,0 +1,53 @@
+package rom
+
+import "testing"
+
+func TestNewHeader(t *testing.T) {
+	rom := make([]byte, 64*1024)
+	copy(rom[HeaderLogo:], NintendoLogo[:])
+	copy(rom[HeaderOldTitleStart:], "POUSSIN")
+	rom[HeaderCGBFlag] = 0x80
+	rom[HeaderOldLicensee] = oldLicenseeUseNewCode
+	copy(rom[HeaderNewLicensee:], "01")
+	rom[HeaderROMSize] = 0x01
+	rom[HeaderRAMSize] = 0x03
+	rom[HeaderVersion] = 0x02
+	rom[HeaderChecksum] = ComputeHeaderChecksum(rom)
+	sum := ComputeGlobalChecksum(rom)
+	rom[HeaderGlobalChecksum] = byte(sum >> 8)
+	rom[HeaderGlobalChecksum+1] = byte(sum)
+
+	h, err := NewHeader(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Verify(); err != nil {
+		t.Error(err)
+	}
+	if !h.GlobalChecksumValid {
+		t.Error("expected a valid global checksum")
+	}
+	if h.Title != "POUSSIN" || h.Version != 0x02 || h.Licensee() != "Nintendo R&D1" {
+		t.Errorf("unexpected header: %s, %s", h.String(), h.Licensee())
+	}
+	if h.ROMBanks != 4 || h.ROMBytes != 64*1024 || h.RAMBanks != 4 || h.RAMBytes != 32*1024 {
+		t.Errorf("unexpected sizes: %+v", h)
+	}
+
+	rom[HeaderLogo] = 0
+	if h, _ := NewHeader(rom); h.Verify() == nil {
+		t.Error("expected an invalid logo")
+	}
+}
+
+func TestNewHeaderMalformed(t *testing.T) {
+	if _, err := NewHeader(make([]byte, 0x100)); err == nil {
+		t.Error("expected an error on a short file")
+	}
+
+	rom := make([]byte, HeaderEnd)
+	rom[HeaderROMSize] = 0x42
+	if _, err := NewHeader(rom); err == nil {
+		t.Error("expected an error on an invalid ROM size")
+	}
+}
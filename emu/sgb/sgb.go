@@ -0,0 +1,534 @@
+// Package sgb emulates the Super Game Boy: command packets sent through the
+// joypad register, palettes, attributes, borders, and multiplayer.
+package sgb
+
+import (
+	"image"
+
+	"github.com/L-P/poussin/emu/ppu"
+)
+
+// Size of the SNES picture, the Game Boy screen is centered in the border.
+const (
+	Width   = 256
+	Height  = 224
+	screenX = (Width - ppu.DotMatrixWidth) / 2
+	screenY = (Height - ppu.DotMatrixHeight) / 2
+)
+
+// Commands
+const (
+	CommandPAL01   = 0x00
+	CommandPAL23   = 0x01
+	CommandPAL03   = 0x02
+	CommandPAL12   = 0x03
+	CommandATTRBLK = 0x04
+	CommandATTRLIN = 0x05
+	CommandATTRDIV = 0x06
+	CommandATTRCHR = 0x07
+	CommandPALSET  = 0x0A
+	CommandPALTRN  = 0x0B
+	CommandMLTREQ  = 0x11
+	CommandCHRTRN  = 0x13
+	CommandPCTTRN  = 0x14
+	CommandATTRTRN = 0x15
+	CommandATTRSET = 0x16
+	CommandMASKEN  = 0x17
+)
+
+// MASK_EN modes
+const (
+	MaskCancel = iota
+	MaskFreeze
+	MaskBlack
+	MaskColor0
+)
+
+const (
+	packetSize = 16
+
+	// The screen is split in 8x8 cells for attributes.
+	cellsWidth  = ppu.DotMatrixWidth / 8
+	cellsHeight = ppu.DotMatrixHeight / 8
+
+	// Border tilemap size in tiles.
+	borderWidth  = Width / 8
+	borderHeight = 32
+
+	// VRAM transfers copy 4 KiB from the displayed tiles.
+	transferSize = 0x1000
+
+	attrFileSize  = cellsWidth * cellsHeight / 4
+	attrFileCount = 45
+)
+
+// defaultPalette is the 1-A palette the SGB uses until the game sets one.
+var defaultPalette = [4]uint16{0x67BF, 0x265B, 0x10B5, 0x2866}
+
+// SGB holds the Super Game Boy state, it is a ppu.Colorizer.
+type SGB struct {
+	// {{{ Packet reception
+	receiving bool
+	waitHigh  bool
+	bitCount  int
+	packet    [packetSize]byte
+	command   []byte // packets of the command being received
+	lastP1    byte
+	// }}}
+
+	palettes       [4][4]uint16
+	systemPalettes [512][4]uint16
+	attrs          [cellsWidth * cellsHeight]byte
+	attrFiles      [attrFileCount][attrFileSize]byte
+	mask           byte
+
+	// Command waiting for the next frame to read its data from the screen.
+	pendingTransfer []byte
+
+	borderTiles    [256 * 32]byte
+	borderMap      [borderWidth * borderHeight]uint16
+	borderPalettes [4][16]uint16
+
+	players int
+	player  int
+
+	screen      [ppu.DotMatrixWidth * ppu.DotMatrixHeight]uint16
+	buffers     [2]*image.RGBA
+	bufferIndex int
+}
+
+// New creates a SGB with its power-on palettes and no border.
+func New() *SGB {
+	s := &SGB{
+		players: 1,
+		buffers: [2]*image.RGBA{
+			image.NewRGBA(image.Rect(0, 0, Width, Height)),
+			image.NewRGBA(image.Rect(0, 0, Width, Height)),
+		},
+	}
+
+	for i := range s.palettes {
+		s.palettes[i] = defaultPalette
+	}
+
+	return s
+}
+
+// WriteP1 receives the P14/P15 pulses written to the joypad register.
+// A packet starts with both lines low, each bit is sent by pulling one line
+// low (P14 for 0, P15 for 1) then both high.
+func (s *SGB) WriteP1(value byte) {
+	lines := value & 0x30
+	defer func() { s.lastP1 = lines }()
+
+	switch lines {
+	case 0x00:
+		s.receiving = true
+		s.waitHigh = true
+		s.bitCount = 0
+		s.packet = [packetSize]byte{}
+	case 0x10, 0x20:
+		if !s.receiving || s.waitHigh {
+			return
+		}
+		s.waitHigh = true
+		s.receiveBit(lines == 0x10)
+	case 0x30:
+		// P15 going high switches to the next joypad with MLT_REQ.
+		if !s.receiving && s.lastP1 == 0x10 {
+			s.player = (s.player + 1) % s.players
+		}
+		s.waitHigh = false
+	}
+}
+
+func (s *SGB) receiveBit(bit bool) {
+	// The 128 packet bits are followed by a 0 stop bit.
+	if s.bitCount == packetSize*8 {
+		s.receiving = false
+		if !bit {
+			s.receivePacket()
+		}
+		return
+	}
+
+	if bit {
+		s.packet[s.bitCount/8] |= 1 << uint(s.bitCount%8)
+	}
+	s.bitCount++
+}
+
+func (s *SGB) receivePacket() {
+	s.command = append(s.command, s.packet[:]...)
+
+	count := int(s.command[0] & 0x07)
+	if count == 0 {
+		count = 1
+	}
+	if len(s.command) < count*packetSize {
+		return
+	}
+
+	s.run(s.command)
+	s.command = nil
+}
+
+// Player returns the index of the joypad currently read.
+func (s *SGB) Player() int {
+	return s.player
+}
+
+// JoypadID returns the lower nibble of P1 when neither buttons nor arrows
+// are selected, it identifies the current joypad.
+func (s *SGB) JoypadID() byte {
+	return 0x0F - byte(s.player)
+}
+
+func (s *SGB) run(data []byte) {
+	switch data[0] >> 3 {
+	case CommandPAL01:
+		s.setPalettes(0, 1, data)
+	case CommandPAL23:
+		s.setPalettes(2, 3, data)
+	case CommandPAL03:
+		s.setPalettes(0, 3, data)
+	case CommandPAL12:
+		s.setPalettes(1, 2, data)
+	case CommandATTRBLK:
+		s.attrBlock(data)
+	case CommandATTRLIN:
+		s.attrLine(data)
+	case CommandATTRDIV:
+		s.attrDivide(data)
+	case CommandATTRCHR:
+		s.attrCharacter(data)
+	case CommandPALSET:
+		s.paletteSet(data)
+	case CommandMLTREQ:
+		s.multiplayer(data[1])
+	case CommandATTRSET:
+		s.attrSet(data[1])
+	case CommandMASKEN:
+		s.mask = data[1] & 0x03
+	case CommandPALTRN, CommandCHRTRN, CommandPCTTRN, CommandATTRTRN:
+		s.pendingTransfer = append([]byte(nil), data...)
+	}
+}
+
+func color16(data []byte) uint16 {
+	return uint16(data[0]) | uint16(data[1])<<8
+}
+
+// setPalettes handles PAL01, PAL23, PAL03, and PAL12. Color 0 is shared by
+// all palettes.
+func (s *SGB) setPalettes(a, b int, data []byte) {
+	color0 := color16(data[1:])
+	for i := range s.palettes {
+		s.palettes[i][0] = color0
+	}
+
+	for i := 1; i < 4; i++ {
+		s.palettes[a][i] = color16(data[1+i*2:])
+		s.palettes[b][i] = color16(data[7+i*2:])
+	}
+}
+
+// paletteSet handles PAL_SET, copying system palettes from PAL_TRN.
+func (s *SGB) paletteSet(data []byte) {
+	for i := range s.palettes {
+		s.palettes[i] = s.systemPalettes[color16(data[1+i*2:])&0x1FF]
+	}
+
+	color0 := s.palettes[0][0]
+	for i := range s.palettes {
+		s.palettes[i][0] = color0
+	}
+
+	if data[9]&0x80 == 0x80 {
+		s.attrSet(data[9] & 0x3F)
+	}
+	if data[9]&0x40 == 0x40 {
+		s.mask = MaskCancel
+	}
+}
+
+func (s *SGB) multiplayer(v byte) {
+	switch v & 0x03 {
+	case 0x01:
+		s.players = 2
+	case 0x03:
+		s.players = 4
+	default:
+		s.players = 1
+	}
+
+	s.player = 0
+}
+
+func (s *SGB) setAttr(x, y int, palette byte) {
+	if x < 0 || x >= cellsWidth || y < 0 || y >= cellsHeight {
+		return
+	}
+
+	s.attrs[y*cellsWidth+x] = palette & 0x03
+}
+
+// attrBlock handles ATTR_BLK, each data set colors the inside, border, and
+// outside of a rectangle.
+func (s *SGB) attrBlock(data []byte) {
+	count := int(data[1])
+	for i := 0; i < count && 2+i*6+6 <= len(data); i++ {
+		set := data[2+i*6:]
+		control := set[0] & 0x07
+		inside := set[1] & 0x03
+		border := (set[1] >> 2) & 0x03
+		outside := (set[1] >> 4) & 0x03
+		x1, y1, x2, y2 := int(set[2]), int(set[3]), int(set[4]), int(set[5])
+
+		// Changing only the inside or outside also changes the border.
+		switch control {
+		case 0x01:
+			control, border = 0x03, inside
+		case 0x04:
+			control, border = 0x06, outside
+		}
+
+		for y := 0; y < cellsHeight; y++ {
+			for x := 0; x < cellsWidth; x++ {
+				switch {
+				case x > x1 && x < x2 && y > y1 && y < y2:
+					if control&0x01 == 0x01 {
+						s.setAttr(x, y, inside)
+					}
+				case x < x1 || x > x2 || y < y1 || y > y2:
+					if control&0x04 == 0x04 {
+						s.setAttr(x, y, outside)
+					}
+				default:
+					if control&0x02 == 0x02 {
+						s.setAttr(x, y, border)
+					}
+				}
+			}
+		}
+	}
+}
+
+// attrLine handles ATTR_LIN, coloring whole lines or columns of cells.
+func (s *SGB) attrLine(data []byte) {
+	count := int(data[1])
+	for i := 0; i < count && 2+i < len(data); i++ {
+		v := data[2+i]
+		line := int(v & 0x1F)
+		palette := (v >> 5) & 0x03
+
+		for j := 0; j < cellsWidth || j < cellsHeight; j++ {
+			if v&0x80 == 0x80 {
+				s.setAttr(j, line, palette)
+			} else {
+				s.setAttr(line, j, palette)
+			}
+		}
+	}
+}
+
+// attrDivide handles ATTR_DIV, splitting the screen in two along a line.
+func (s *SGB) attrDivide(data []byte) {
+	after := data[1] & 0x03
+	before := (data[1] >> 2) & 0x03
+	on := (data[1] >> 4) & 0x03
+	horizontal := data[1]&0x40 == 0x40
+	split := int(data[2])
+
+	for y := 0; y < cellsHeight; y++ {
+		for x := 0; x < cellsWidth; x++ {
+			v := x
+			if horizontal {
+				v = y
+			}
+
+			switch {
+			case v < split:
+				s.setAttr(x, y, before)
+			case v == split:
+				s.setAttr(x, y, on)
+			default:
+				s.setAttr(x, y, after)
+			}
+		}
+	}
+}
+
+// attrCharacter handles ATTR_CHR, setting cells one by one from a starting
+// position, four cells per byte.
+func (s *SGB) attrCharacter(data []byte) {
+	x, y := int(data[1]), int(data[2])
+	count := int(data[3]) | int(data[4])<<8
+	vertical := data[5] == 0x01
+
+	for i := 0; i < count && 6+i/4 < len(data); i++ {
+		palette := (data[6+i/4] >> uint(6-(i%4)*2)) & 0x03
+		s.setAttr(x, y, palette)
+
+		if vertical {
+			if y++; y >= cellsHeight {
+				y = 0
+				x++
+			}
+		} else {
+			if x++; x >= cellsWidth {
+				x = 0
+				y++
+			}
+		}
+	}
+}
+
+// attrSet handles ATTR_SET, applying an attribute file from ATTR_TRN.
+func (s *SGB) attrSet(v byte) {
+	file := int(v & 0x3F)
+	if file < attrFileCount {
+		for i := range s.attrs {
+			s.attrs[i] = (s.attrFiles[file][i/4] >> uint(6-(i%4)*2)) & 0x03
+		}
+	}
+
+	if v&0x40 == 0x40 {
+		s.mask = MaskCancel
+	}
+}
+
+// transfer runs the pending VRAM transfer using the data displayed on screen.
+func (s *SGB) transfer(shades []byte) {
+	data := s.pendingTransfer
+	s.pendingTransfer = nil
+	vram := screenTiles(shades)
+
+	switch data[0] >> 3 {
+	case CommandPALTRN:
+		for i := range s.systemPalettes {
+			for j := range s.systemPalettes[i] {
+				s.systemPalettes[i][j] = color16(vram[i*8+j*2:])
+			}
+		}
+	case CommandCHRTRN:
+		offset := 0
+		if data[1]&0x01 == 0x01 {
+			offset = len(s.borderTiles) / 2
+		}
+		copy(s.borderTiles[offset:], vram[:len(s.borderTiles)/2])
+	case CommandPCTTRN:
+		for i := range s.borderMap {
+			s.borderMap[i] = color16(vram[i*2:])
+		}
+		for i := range s.borderPalettes {
+			for j := range s.borderPalettes[i] {
+				s.borderPalettes[i][j] = color16(vram[0x800+i*32+j*2:])
+			}
+		}
+	case CommandATTRTRN:
+		for i := range s.attrFiles {
+			copy(s.attrFiles[i][:], vram[i*attrFileSize:])
+		}
+	}
+}
+
+// screenTiles converts the displayed picture back to 2bpp tile data, tiles
+// being read left to right, top to bottom.
+func screenTiles(shades []byte) []byte {
+	vram := make([]byte, transferSize)
+	for i := 0; i < transferSize/16; i++ {
+		tileX, tileY := i%cellsWidth, i/cellsWidth
+		for y := 0; y < 8; y++ {
+			var a, b byte
+			for x := 0; x < 8; x++ {
+				shade := shades[(tileY*8+y)*ppu.DotMatrixWidth+tileX*8+x]
+				a |= (shade & 0x01) << uint(7-x)
+				b |= ((shade >> 1) & 0x01) << uint(7-x)
+			}
+			vram[i*16+y*2] = a
+			vram[i*16+y*2+1] = b
+		}
+	}
+
+	return vram
+}
+
+// Colorize implements ppu.Colorizer.
+func (s *SGB) Colorize(shades []byte) *image.RGBA {
+	if s.pendingTransfer != nil {
+		s.transfer(shades)
+	}
+
+	if s.mask != MaskFreeze {
+		for i, shade := range shades {
+			var c uint16
+			switch s.mask {
+			case MaskBlack:
+				c = 0x0000
+			case MaskColor0:
+				c = s.palettes[0][0]
+			default:
+				cell := (i/ppu.DotMatrixWidth/8)*cellsWidth + (i%ppu.DotMatrixWidth)/8
+				c = s.palettes[s.attrs[cell]][shade&0x03]
+			}
+			s.screen[i] = c
+		}
+	}
+
+	img := s.buffers[s.bufferIndex]
+	s.bufferIndex = (s.bufferIndex + 1) % 2
+	s.draw(img)
+
+	return img
+}
+
+// draw composes the SNES picture: backdrop, screen, then the border above.
+func (s *SGB) draw(img *image.RGBA) {
+	backdrop := ppu.RGB555ToRGBA(s.palettes[0][0])
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			img.SetRGBA(x, y, backdrop)
+		}
+	}
+
+	for i, c := range s.screen {
+		img.SetRGBA(screenX+i%ppu.DotMatrixWidth, screenY+i/ppu.DotMatrixWidth, ppu.RGB555ToRGBA(c))
+	}
+
+	for i, entry := range s.borderMap[:borderWidth*(Height/8)] {
+		s.drawBorderTile(img, (i%borderWidth)*8, (i/borderWidth)*8, entry)
+	}
+}
+
+// drawBorderTile draws a 4bpp SNES tile, color 0 is transparent.
+func (s *SGB) drawBorderTile(img *image.RGBA, tx, ty int, entry uint16) {
+	tile := s.borderTiles[int(entry&0xFF)*32:]
+	palette := &s.borderPalettes[(entry>>10)&0x03]
+	xFlip := entry&(1<<14) != 0
+	yFlip := entry&(1<<15) != 0
+
+	for y := 0; y < 8; y++ {
+		row := y
+		if yFlip {
+			row = 7 - y
+		}
+
+		for x := 0; x < 8; x++ {
+			bit := uint(7 - x)
+			if xFlip {
+				bit = uint(x)
+			}
+
+			index := (tile[row*2]>>bit)&1 |
+				((tile[row*2+1]>>bit)&1)<<1 |
+				((tile[16+row*2]>>bit)&1)<<2 |
+				((tile[16+row*2+1]>>bit)&1)<<3
+			if index == 0 {
+				continue
+			}
+
+			img.SetRGBA(tx+x, ty+y, ppu.RGB555ToRGBA(palette[index]))
+		}
+	}
+}
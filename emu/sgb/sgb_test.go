@@ -0,0 +1,79 @@
+package sgb
+
+import (
+	"testing"
+
+	"github.com/L-P/poussin/emu/ppu"
+)
+
+// send writes a command to P1 the way games do, one bit per pulse.
+func send(s *SGB, packets ...[packetSize]byte) {
+	for _, packet := range packets {
+		s.WriteP1(0x00)
+		s.WriteP1(0x30)
+		for i := 0; i < packetSize*8; i++ {
+			if packet[i/8]&(1<<uint(i%8)) != 0 {
+				s.WriteP1(0x10)
+			} else {
+				s.WriteP1(0x20)
+			}
+			s.WriteP1(0x30)
+		}
+		s.WriteP1(0x20) // stop bit
+		s.WriteP1(0x30)
+	}
+}
+
+func TestPAL01AndATTRDIV(t *testing.T) {
+	s := New()
+	send(s,
+		[packetSize]byte{
+			CommandPAL01<<3 | 1,
+			0x00, 0x00, // shared color 0
+			0x1F, 0x00, 0x1F, 0x00, 0x1F, 0x00, // palette 0: red
+			0xE0, 0x03, 0xE0, 0x03, 0xE0, 0x03, // palette 1: green
+		},
+		[packetSize]byte{
+			CommandATTRDIV<<3 | 1,
+			0x01 | 0x01<<4, // right and line: palette 1, left: palette 0
+			10,             // split at column 10
+		},
+	)
+
+	shades := make([]byte, ppu.DotMatrixWidth*ppu.DotMatrixHeight)
+	for i := range shades {
+		shades[i] = 3
+	}
+
+	img := s.Colorize(shades)
+	if c := img.RGBAAt(screenX, screenY); c.R != 0xFF || c.G != 0 {
+		t.Errorf("expected red on the left, got %v", c)
+	}
+	if c := img.RGBAAt(screenX+ppu.DotMatrixWidth-1, screenY); c.G != 0xFF || c.R != 0 {
+		t.Errorf("expected green on the right, got %v", c)
+	}
+	if c := img.RGBAAt(0, 0); c.R != 0 || c.G != 0 || c.B != 0 {
+		t.Errorf("expected the backdrop to use color 0, got %v", c)
+	}
+}
+
+func TestMultiplayer(t *testing.T) {
+	s := New()
+	send(s, [packetSize]byte{CommandMLTREQ<<3 | 1, 0x01})
+
+	if id := s.JoypadID(); id != 0x0F {
+		t.Fatalf("expected first joypad, got %X", id)
+	}
+
+	s.WriteP1(0x10)
+	s.WriteP1(0x30)
+	if id := s.JoypadID(); id != 0x0E {
+		t.Fatalf("expected second joypad, got %X", id)
+	}
+
+	s.WriteP1(0x10)
+	s.WriteP1(0x30)
+	if id := s.JoypadID(); id != 0x0F {
+		t.Fatalf("expected to wrap to the first joypad, got %X", id)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	romheader "github.com/L-P/poussin/emu/rom"
+)
+
+// romInfo is what the info command reports about a ROM.
+type romInfo struct {
+	Path                string `json:"path"`
+	Error               string `json:"error,omitempty"`
+	Title               string `json:"title"`
+	Manufacturer        string `json:"manufacturer,omitempty"`
+	Licensee            string `json:"licensee"`
+	CartridgeType       string `json:"cartridgeType"`
+	ROMSizeCode         byte   `json:"romSizeCode"`
+	ROMBytes            int    `json:"romBytes"`
+	ROMBanks            int    `json:"romBanks"`
+	RAMSizeCode         byte   `json:"ramSizeCode"`
+	RAMBytes            int    `json:"ramBytes"`
+	RAMBanks            int    `json:"ramBanks"`
+	CGB                 bool   `json:"cgb"`
+	CGBOnly             bool   `json:"cgbOnly"`
+	SGB                 bool   `json:"sgb"`
+	Japanese            bool   `json:"japanese"`
+	Version             byte   `json:"version"`
+	LogoValid           bool   `json:"logoValid"`
+	HeaderChecksum      byte   `json:"headerChecksum"`
+	HeaderChecksumValid bool   `json:"headerChecksumValid"`
+	GlobalChecksum      uint16 `json:"globalChecksum"`
+	GlobalChecksumValid bool   `json:"globalChecksumValid"`
+
+	// parsed is set when the header could be read, even if it is invalid.
+	parsed bool
+}
+
+// runInfo prints the header of the ROMs given as arguments without starting
+// the emulator.
+func runInfo(args []string) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "output a JSON array instead of text")
+	var patches stringList
+	fs.Var(&patches, "patch", "inspect the ROMs patched with `file`, can be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: poussin info [-json] [-patch FILE]... ROM...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	infos := make([]romInfo, 0, fs.NArg())
+	failed := false
+	for _, path := range fs.Args() {
+		info := readROMInfo(path, patches)
+		if info.Error != "" {
+			failed = true
+		}
+		infos = append(infos, info)
+	}
+
+	if err := writeROMInfos(os.Stdout, infos, *asJSON); err != nil {
+		return err
+	}
+
+	if failed {
+		return errors.New("some ROMs could not be read")
+	}
+
+	return nil
+}
+
+// writeROMInfos prints the ROM headers as text or as a JSON array.
+func writeROMInfos(w io.Writer, infos []romInfo, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		printROMInfo(w, info)
+	}
+
+	return nil
+}
+
+// readROMInfo parses the header of the ROM at path, errors are reported in
+// the returned romInfo so the other ROMs can still be listed.
+func readROMInfo(path string, patches []string) romInfo {
+	info := romInfo{Path: path}
+
+	rom, err := loadROM(path, patches)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	// Invalid sizes are reported along with the rest of the header as it
+	// helps finding out what is wrong with it.
+	h, err := romheader.NewHeader(rom.Data)
+	if err != nil {
+		info.Error = err.Error()
+		if len(rom.Data) < romheader.HeaderEnd {
+			return info
+		}
+	}
+
+	info.parsed = true
+	info.Title = h.Title
+	info.Manufacturer = h.Manufacturer
+	info.Licensee = h.Licensee()
+	info.CartridgeType = romheader.CartridgeTypes[h.CartridgeType]
+	info.ROMSizeCode = h.ROMSize
+	info.ROMBytes = h.ROMBytes
+	info.ROMBanks = h.ROMBanks
+	info.RAMSizeCode = h.RAMSize
+	info.RAMBytes = h.RAMBytes
+	info.RAMBanks = h.RAMBanks
+	info.CGB = !h.DMG
+	info.CGBOnly = h.CGBOnly
+	info.SGB = h.SGBSupport
+	info.Japanese = h.Japanese
+	info.Version = h.Version
+	info.LogoValid = h.LogoValid
+	info.HeaderChecksum = h.HeaderChecksum
+	info.HeaderChecksumValid = h.HeaderChecksumValid
+	info.GlobalChecksum = h.GlobalChecksum
+	info.GlobalChecksumValid = h.GlobalChecksumValid
+
+	return info
+}
+
+func printROMInfo(w io.Writer, info romInfo) {
+	fmt.Fprintln(w, info.Path)
+	if info.Error != "" {
+		fmt.Fprintf(w, "  Error:           %s\n", info.Error)
+	}
+	if !info.parsed {
+		return
+	}
+
+	region := "World"
+	if info.Japanese {
+		region = "Japan"
+	}
+
+	fmt.Fprintf(w, "  Title:           %s\n", info.Title)
+	if info.Manufacturer != "" {
+		fmt.Fprintf(w, "  Manufacturer:    %s\n", info.Manufacturer)
+	}
+	fmt.Fprintf(w, "  Licensee:        %s\n", info.Licensee)
+	fmt.Fprintf(w, "  Cartridge:       %s\n", info.CartridgeType)
+	fmt.Fprintf(w, "  ROM:             %s\n", romSize(info.ROMSizeCode, info.ROMBytes, info.ROMBanks))
+	fmt.Fprintf(w, "  RAM:             %s\n", romSize(info.RAMSizeCode, info.RAMBytes, info.RAMBanks))
+	fmt.Fprintf(w, "  CGB:             %s\n", cgbSupport(info))
+	fmt.Fprintf(w, "  SGB:             %s\n", yesNo(info.SGB))
+	fmt.Fprintf(w, "  Region:          %s\n", region)
+	fmt.Fprintf(w, "  Version:         %02X\n", info.Version)
+	fmt.Fprintf(w, "  Logo:            %s\n", validity(info.LogoValid))
+	fmt.Fprintf(w, "  Header checksum: %02X (%s)\n", info.HeaderChecksum, validity(info.HeaderChecksumValid))
+	fmt.Fprintf(w, "  Global checksum: %04X (%s)\n", info.GlobalChecksum, validity(info.GlobalChecksumValid))
+}
+
+// romSize formats a ROM or RAM size, unknown size codes are printed as is.
+func romSize(code byte, bytes, banks int) string {
+	if bytes == 0 && code != 0 {
+		return fmt.Sprintf("unknown (code %02X)", code)
+	}
+
+	return fmt.Sprintf("%d KiB (%d banks)", bytes/1024, banks)
+}
+
+func cgbSupport(info romInfo) string {
+	switch {
+	case info.CGBOnly:
+		return "required"
+	case info.CGB:
+		return "supported"
+	default:
+		return "no"
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func validity(valid bool) string {
+	if valid {
+		return "valid"
+	}
+
+	return "INVALID"
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	romheader "github.com/L-P/poussin/emu/rom"
+)
+
+func TestROMInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poussin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 0x8000)
+	copy(data[romheader.HeaderOldTitleStart:], "HOMEBREW")
+	copy(data[romheader.HeaderLogo:], romheader.NintendoLogo[:])
+	data[romheader.HeaderCartridgeType] = 0x01
+	data[romheader.HeaderROMSize] = 0x42
+	data[romheader.HeaderRAMSize] = 0x02
+	data[romheader.HeaderChecksum] = romheader.ComputeHeaderChecksum(data)
+
+	path := filepath.Join(dir, "homebrew.gb")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	infos := []romInfo{
+		readROMInfo(path, nil),
+		readROMInfo(filepath.Join(dir, "missing.gb"), nil),
+	}
+
+	info := infos[0]
+	if info.Error != "invalid ROM size code: 42" {
+		t.Errorf("expected the ROM size code to be reported, got %q", info.Error)
+	}
+	if info.Title != "HOMEBREW" || info.CartridgeType != "MBC1" || info.RAMBytes != 8*1024 || !info.HeaderChecksumValid {
+		t.Errorf("expected the rest of the header to be parsed, got %+v", info)
+	}
+
+	var text bytes.Buffer
+	if err := writeROMInfos(&text, infos, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"  Error:           invalid ROM size code: 42\n  Title:           HOMEBREW\n",
+		"  ROM:             unknown (code 42)\n  RAM:             8 KiB (1 banks)\n",
+		"  Header checksum: ",
+		"missing.gb\n  Error:           ",
+	} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("expected the output to contain %q, got:\n%s", expected, text.String())
+		}
+	}
+	if strings.Count(text.String(), "Title:") != 1 {
+		t.Errorf("expected only the readable ROM to have a title, got:\n%s", text.String())
+	}
+
+	var js bytes.Buffer
+	if err := writeROMInfos(&js, infos, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("expected 2 ROMs, got %d", len(decoded))
+	}
+	if decoded[0]["title"] != "HOMEBREW" || decoded[0]["romSizeCode"] != float64(0x42) || decoded[0]["error"] == nil {
+		t.Errorf("expected the JSON to hold the header and the error, got %v", decoded[0])
+	}
+}
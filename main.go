@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"image"
@@ -12,14 +13,53 @@ import (
 
 	"github.com/L-P/poussin/emu"
 	"github.com/L-P/poussin/emu/cpu"
+	"github.com/L-P/poussin/emu/link"
+	"github.com/L-P/poussin/emu/ppu"
+	"github.com/L-P/poussin/emu/printer"
+	romheader "github.com/L-P/poussin/emu/rom"
+	"github.com/L-P/poussin/emu/sgb"
 	"github.com/L-P/poussin/renderer/gl"
 )
 
+// options holds the command line flags that alter the emulation.
+type options struct {
+	linkListen  string
+	linkConnect string
+	printerDir  string
+	model       string
+	palette     string
+	bootLogo    bool
+	patches     stringList
+}
+
 func main() {
 	log.SetOutput(os.Stderr)
 
+	if len(os.Args) > 1 && os.Args[1] == "info" {
+		if err := runInfo(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "disasm" {
+		if err := runDisasm(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
 	var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+
+	var opts options
+	flag.StringVar(&opts.linkListen, "link-listen", "", "wait for a link cable partner on `addr`")
+	flag.StringVar(&opts.linkConnect, "link-connect", "", "connect the link cable to a partner waiting on `addr`")
+	flag.StringVar(&opts.printerDir, "printer", "", "plug a Game Boy Printer saving its prints in `dir`")
+	flag.StringVar(&opts.model, "model", "", "emulated hardware `model`: dmg0, dmg, mgb, sgb, sgb2, or cgb (default: cgb for CGB games, dmg otherwise)")
+	flag.StringVar(&opts.palette, "palette", "", "colorize DMG games with the CGB palette selected by `combo`, eg. up+a (implies -model cgb)")
+	flag.BoolVar(&opts.bootLogo, "boot-logo", false, "scroll the cartridge logo at startup when no BOOTROM is given")
+	flag.Var(&opts.patches, "patch", "apply the IPS, UPS, or BPS patch `file` to the ROM, can be repeated (default: ROM.ips, .ups, or .bps if it exists)")
 	flag.Parse()
 
 	if *cpuprofile != "" {
@@ -33,7 +73,7 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	if err := run(); err != nil {
+	if err := run(opts); err != nil {
 		log.Fatal(err)
 	}
 
@@ -50,12 +90,62 @@ func main() {
 	}
 }
 
-func run() error {
+func run(opts options) (err error) {
 	if len(flag.Args()) < 1 {
-		fmt.Println("Usage: poussin [-cpuprofile FILE] [-memprofile FILE] [BOOTROM] ROM")
+		fmt.Println("Usage: poussin [-cpuprofile FILE] [-memprofile FILE] [-model MODEL] [-palette COMBO] [-boot-logo] [-patch FILE]... [-link-listen ADDR|-link-connect ADDR|-printer DIR] [BOOTROM] ROM")
+		fmt.Println("       poussin info [-json] [-patch FILE]... ROM...")
+		fmt.Println("       poussin disasm [-o FILE] [-patch FILE]... ROM")
+		fmt.Println("ROM can be a .zip or .gz archive, use archive.zip:entry.gb to pick a zip entry.")
 		os.Exit(1)
 	}
 
+	var bootRomPath string
+	var romPath string
+	if len(flag.Args()) == 2 {
+		bootRomPath = flag.Args()[0]
+		romPath = flag.Args()[1]
+	} else {
+		romPath = flag.Args()[0]
+	}
+
+	rom, err := loadROM(romPath, opts.patches)
+	if err != nil {
+		return err
+	}
+
+	if opts.bootLogo && bootRomPath != "" {
+		return errors.New("-boot-logo replaces the BOOTROM, they cannot be used together")
+	}
+
+	header, err := romheader.NewHeader(rom.Data)
+	if err != nil {
+		log.Printf("warning: %s, use poussin info for details", err)
+	}
+
+	model, err := selectModel(opts, header)
+	if err != nil {
+		return err
+	}
+
+	palettes, err := compatPalettes(opts)
+	if err != nil {
+		return err
+	}
+
+	// Connect before creating the debugger as it takes over the terminal.
+	peer, err := connectSerial(opts)
+	if err != nil {
+		return err
+	}
+	if peer != nil {
+		// The printer saves its last strip when closed.
+		defer func() {
+			if cerr := peer.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+	}
+
 	input := make(chan cpu.JoypadState, 1)
 	nextFrame := make(chan *image.RGBA, 1)
 	gb, err := emu.NewGameboy(nextFrame, input)
@@ -64,13 +154,18 @@ func run() error {
 	}
 	defer gb.Close()
 
-	var bootRomPath string
-	var romPath string
-	if len(flag.Args()) == 2 {
-		bootRomPath = flag.Args()[0]
-		romPath = flag.Args()[1]
-	} else {
-		romPath = flag.Args()[0]
+	if peer != nil {
+		gb.ConnectSerial(peer)
+	}
+
+	gb.SetModel(model)
+	width, height := ppu.DotMatrixWidth, ppu.DotMatrixHeight
+	if model.IsSGB() {
+		width, height = sgb.Width, sgb.Height
+	}
+
+	if palettes != nil {
+		gb.SetCompatPalettes(*palettes)
 	}
 
 	if bootRomPath != "" {
@@ -81,19 +176,35 @@ func run() error {
 		if err := gb.LoadBootROM(bootRom); err != nil {
 			return err
 		}
-	} else {
-		gb.SimulateBoot()
 	}
 
-	rom, err := ioutil.ReadFile(romPath)
+	if err := gb.LoadROM(rom.Data); err != nil {
+		return err
+	}
+
+	cheats, err := loadCheats(rom)
 	if err != nil {
 		return err
 	}
-	if err := gb.LoadROM(rom); err != nil {
+	gb.SetCheats(cheats)
+
+	if err := gb.LoadSymbols(rom.Base + symbolsExtension); err != nil {
 		return err
 	}
 
-	r, err := gl.New()
+	if err := gb.LoadBreakpoints(rom.Base + breakpointsExtension); err != nil {
+		return err
+	}
+
+	if bootRomPath == "" {
+		if opts.bootLogo {
+			gb.EnableBootAnimation()
+		} else {
+			gb.SimulateBoot()
+		}
+	}
+
+	r, err := gl.New(width, height)
 	if err != nil {
 		return err
 	}
@@ -118,3 +229,73 @@ func run() error {
 
 	return nil
 }
+
+// selectModel returns the model requested on the command line, defaulting to
+// the hardware the game was made for.
+func selectModel(opts options, h romheader.Header) (cpu.Model, error) {
+	if opts.model == "" {
+		if opts.palette != "" || !h.DMG {
+			return cpu.ModelCGB, nil
+		}
+
+		return cpu.ModelDMG, nil
+	}
+
+	model, err := cpu.ParseModel(opts.model)
+	if err != nil {
+		return model, err
+	}
+
+	if opts.palette != "" && model != cpu.ModelCGB {
+		return model, errors.New("-palette can only be used with -model cgb")
+	}
+
+	return model, nil
+}
+
+// compatPalettes returns the CGB palettes requested on the command line, nil
+// if the boot ROM should pick them.
+func compatPalettes(opts options) (*ppu.CompatPalettes, error) {
+	if opts.palette == "" {
+		return nil, nil
+	}
+
+	palettes, err := ppu.ManualCompatPalettes(opts.palette)
+	if err != nil {
+		return nil, err
+	}
+
+	return &palettes, nil
+}
+
+// serialDevice is a cpu.SerialPeer that needs cleaning up.
+type serialDevice interface {
+	cpu.SerialPeer
+	Close() error
+}
+
+// connectSerial creates the device plugged in the link port as requested on
+// the command line, it returns nil if none was.
+func connectSerial(opts options) (serialDevice, error) {
+	count := 0
+	for _, v := range []string{opts.linkListen, opts.linkConnect, opts.printerDir} {
+		if v != "" {
+			count++
+		}
+	}
+	if count > 1 {
+		return nil, errors.New("-link-listen, -link-connect, and -printer are mutually exclusive")
+	}
+
+	switch {
+	case opts.linkListen != "":
+		log.Printf("waiting for a link cable partner on %s", opts.linkListen)
+		return link.Listen(opts.linkListen)
+	case opts.linkConnect != "":
+		return link.Dial(opts.linkConnect)
+	case opts.printerDir != "":
+		return printer.New(opts.printerDir), nil
+	}
+
+	return nil, nil
+}
@@ -6,7 +6,6 @@ import (
 	"runtime"
 
 	"github.com/L-P/poussin/emu/cpu"
-	"github.com/L-P/poussin/emu/ppu"
 	"github.com/go-gl/gl/v4.3-core/gl"
 	"github.com/go-gl/glfw/v3.2/glfw"
 	"github.com/go-gl/mathgl/mgl32"
@@ -19,10 +18,13 @@ type Renderer struct {
 	program uint32
 	vao     uint32
 	texture uint32
+
+	// Size of the frames to display.
+	width, height int32
 }
 
-// New creates a new Renderer.
-func New() (*Renderer, error) {
+// New creates a new Renderer displaying frames of the given size.
+func New(width, height int) (*Renderer, error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
@@ -30,7 +32,7 @@ func New() (*Renderer, error) {
 		return nil, fmt.Errorf("could not init GLFW: %s", err)
 	}
 
-	window := initWindow()
+	window := initWindow(width, height)
 
 	program, err := LoadProgram(shaderDefaultVert, shaderDefaultFrag)
 	if err != nil {
@@ -38,13 +40,15 @@ func New() (*Renderer, error) {
 	}
 
 	vao, _ := getPlane(program)
-	texture := createTexture(ppu.DotMatrixWidth, ppu.DotMatrixHeight)
+	texture := createTexture(int32(width), int32(height))
 
 	return &Renderer{
 		window:  window,
 		program: program,
 		vao:     vao,
 		texture: texture,
+		width:   int32(width),
+		height:  int32(height),
 	}, nil
 }
 
@@ -193,14 +197,14 @@ func updateTexture(texture uint32, rgba *image.RGBA) {
 	)
 }
 
-func initWindow() *glfw.Window {
+func initWindow(width, height int) *glfw.Window {
 	glfw.WindowHint(glfw.Resizable, glfw.True)
 	glfw.WindowHint(glfw.ContextVersionMajor, 4)
 	glfw.WindowHint(glfw.ContextVersionMinor, 3)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
 
-	window, err := glfw.CreateWindow(640, 576, "Poussin", nil, nil)
+	window, err := glfw.CreateWindow(width*4, height*4, "Poussin", nil, nil)
 	if err != nil {
 		panic(fmt.Errorf("could not create window: %s", err))
 	}
@@ -226,7 +230,7 @@ func (r *Renderer) updateViewport() {
 	x2 := int32(width)
 	y2 := int32(height)
 
-	targetRatio := float32(ppu.DotMatrixWidth) / float32(ppu.DotMatrixHeight)
+	targetRatio := float32(r.width) / float32(r.height)
 	ratio := float32(width) / float32(height)
 
 	if ratio > targetRatio {
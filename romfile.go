@@ -0,0 +1,202 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/L-P/poussin/emu/cheat"
+	"github.com/L-P/poussin/emu/patch"
+)
+
+// romFile is a ROM read from disk, possibly from inside an archive.
+type romFile struct {
+	Data []byte
+
+	// Base is the path of the ROM without its extension, or the archive's
+	// when loaded from one. Per-ROM files like battery saves are named
+	// after it.
+	Base string
+}
+
+// patchExtensions are the extensions of the patches applied automatically
+// when found next to the ROM.
+var patchExtensions = []string{".ips", ".ups", ".bps"}
+
+// Extensions of the per-ROM files.
+const (
+	cheatsExtension      = ".cht"
+	breakpointsExtension = ".breakpoints"
+	symbolsExtension     = ".sym"
+)
+
+// romExtensions are the extensions of the files picked from archives.
+var romExtensions = []string{".gb", ".gbc", ".sgb"}
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1F, 0x8B}
+)
+
+// readROM reads a ROM from a raw, zip, or gzip file. A specific zip entry
+// can be selected with archive.zip:entry.gb, otherwise the first ROM in the
+// archive is used.
+func readROM(path string) (romFile, error) {
+	archive, entry := splitArchivePath(path)
+
+	data, err := ioutil.ReadFile(archive)
+	if err != nil {
+		return romFile{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, zipMagic):
+		data, err = readZipEntry(data, entry)
+		archive = trimExt(archive)
+	case bytes.HasPrefix(data, gzipMagic):
+		data, err = readGzip(data)
+		archive = trimExt(archive)
+	case entry != "":
+		return romFile{}, fmt.Errorf("%s is not a zip archive", archive)
+	}
+	if err != nil {
+		return romFile{}, fmt.Errorf("%s: %s", archive, err)
+	}
+
+	return romFile{Data: data, Base: trimROMExt(archive)}, nil
+}
+
+// loadROM reads a ROM and applies the given patches in order. Without any,
+// a patch named after the ROM is applied if it exists.
+func loadROM(path string, patches []string) (romFile, error) {
+	rom, err := readROM(path)
+	if err != nil {
+		return rom, err
+	}
+
+	if len(patches) == 0 {
+		for _, ext := range patchExtensions {
+			if _, err := os.Stat(rom.Base + ext); err == nil {
+				patches = []string{rom.Base + ext}
+				break
+			}
+		}
+	}
+
+	for _, path := range patches {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return rom, err
+		}
+
+		rom.Data, err = patch.Apply(rom.Data, data)
+		if err != nil {
+			return rom, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	return rom, nil
+}
+
+// loadCheats reads the cheat file named after the ROM, it returns nil if
+// there is none.
+func loadCheats(rom romFile) (cheat.List, error) {
+	cheats, err := cheat.Load(rom.Base + cheatsExtension)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return cheats, err
+}
+
+// splitArchivePath splits archive.zip:entry.gb in its two parts, entry is
+// empty if the path points to an existing file.
+func splitArchivePath(path string) (archive, entry string) {
+	if _, err := os.Stat(path); err == nil {
+		return path, ""
+	}
+
+	i := strings.LastIndex(path, ":")
+	if i <= 0 {
+		return path, ""
+	}
+
+	return path[:i], path[i+1:]
+}
+
+func readZipEntry(data []byte, name string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		if (name == "" && isROMName(f.Name)) || (name != "" && f.Name == name) {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+
+			return ioutil.ReadAll(rc)
+		}
+	}
+
+	if name != "" {
+		return nil, fmt.Errorf("no entry named %s", name)
+	}
+
+	return nil, fmt.Errorf("no ROM found, expected one of: %s", strings.Join(romExtensions, ", "))
+}
+
+func readGzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+func isROMName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, v := range romExtensions {
+		if ext == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func trimExt(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// trimROMExt removes the extension of a ROM, leaving other paths untouched
+// so a gzipped game.gb.gz and a plain game.gb share the same base.
+func trimROMExt(path string) string {
+	if isROMName(path) {
+		return trimExt(path)
+	}
+
+	return path
+}
+
+// stringList is a flag that can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadROMFromArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poussin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	zipPath := filepath.Join(dir, "games.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"README.txt", "first.gb", "second.gbc"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	zw.Close()
+	f.Close()
+
+	gzPath := filepath.Join(dir, "game.gb.gz")
+	f, err = os.Create(gzPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte("gzipped"))
+	gw.Close()
+	f.Close()
+
+	tests := []struct {
+		path, data, base string
+	}{
+		{zipPath, "first.gb", filepath.Join(dir, "games")},
+		{zipPath + ":second.gbc", "second.gbc", filepath.Join(dir, "games")},
+		{gzPath, "gzipped", filepath.Join(dir, "game")},
+	}
+
+	for _, v := range tests {
+		rom, err := readROM(v.path)
+		if err != nil {
+			t.Errorf("%s: %s", v.path, err)
+			continue
+		}
+		if string(rom.Data) != v.data || rom.Base != v.base {
+			t.Errorf("%s: got %q, %s", v.path, rom.Data, rom.Base)
+		}
+	}
+
+	if _, err := readROM(zipPath + ":missing.gb"); err == nil {
+		t.Error("expected an error on a missing entry")
+	}
+}